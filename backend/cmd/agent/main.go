@@ -0,0 +1,249 @@
+// Command agent is a build worker for potato-launcher's backend: it
+// long-polls the backend's Agent.Poll endpoint (see internal/agentrpc) for
+// build work, invokes InstanceBuilderBinary locally, streams the build's
+// logs and generated artifacts back, and reports the outcome. Running
+// builds here instead of in the backend process lets one control plane
+// fan builds out to workers with the right OS/JDK for each instance.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/agentrpc"
+)
+
+func getEnv(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return def
+}
+
+func main() {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	backendURL := strings.TrimRight(getEnv("BACKEND_URL", "http://localhost:8000"), "/")
+	agentToken := os.Getenv("AGENT_TOKEN")
+	if agentToken == "" {
+		logger.Error("AGENT_TOKEN is required")
+		os.Exit(1)
+	}
+	builderBinary := getEnv("INSTANCE_BUILDER_BINARY", "instance_builder")
+	workDir := getEnv("AGENT_WORK_DIR", os.TempDir())
+
+	a := &agent{
+		client:        &http.Client{Timeout: 35 * time.Second},
+		backendURL:    backendURL,
+		token:         agentToken,
+		builderBinary: builderBinary,
+		workDir:       workDir,
+		logger:        logger,
+	}
+
+	logger.Info("agent starting", "backend_url", backendURL)
+	for {
+		item, ok, err := a.poll()
+		if err != nil {
+			logger.Error("poll failed", "error", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		logger.Info("received build", "work_id", item.WorkID, "instances", item.InstanceNames)
+		a.runWork(item)
+	}
+}
+
+type agent struct {
+	client        *http.Client
+	backendURL    string
+	token         string
+	builderBinary string
+	workDir       string
+	logger        *slog.Logger
+}
+
+func (a *agent) do(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, a.backendURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return a.client.Do(req)
+}
+
+// poll implements the client side of Agent.Poll.
+func (a *agent) poll() (*agentrpc.WorkItem, bool, error) {
+	resp, err := a.do(http.MethodPost, "/api/v1/agent/poll", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("poll: unexpected status %d", resp.StatusCode)
+	}
+
+	var item agentrpc.WorkItem
+	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
+		return nil, false, fmt.Errorf("poll: decode response: %w", err)
+	}
+	return &item, true, nil
+}
+
+// reportProgress implements the client side of Agent.ReportProgress.
+func (a *agent) reportProgress(workID, line string) {
+	raw, err := json.Marshal(agentrpc.ProgressUpdate{WorkID: workID, Line: line})
+	if err != nil {
+		return
+	}
+	resp, err := a.do(http.MethodPost, "/api/v1/agent/progress", bytes.NewReader(raw))
+	if err != nil {
+		a.logger.Warn("failed to report progress", "work_id", workID, "error", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// uploadArtifact implements the client side of Agent.UploadArtifact for one
+// file.
+func (a *agent) uploadArtifact(workID, relPath string, mode os.FileMode, data []byte) error {
+	path := fmt.Sprintf("/api/v1/agent/artifact?work_id=%s&path=%s&mode=%o",
+		url.QueryEscape(workID), url.QueryEscape(relPath), mode.Perm())
+	resp, err := a.do(http.MethodPost, path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("upload artifact %s: unexpected status %d", relPath, resp.StatusCode)
+	}
+	return nil
+}
+
+// complete implements the client side of Agent.Complete.
+func (a *agent) complete(workID string, exitCode int, buildErr string) {
+	raw, err := json.Marshal(agentrpc.CompleteRequest{WorkID: workID, ExitCode: exitCode, Error: buildErr})
+	if err != nil {
+		return
+	}
+	resp, err := a.do(http.MethodPost, "/api/v1/agent/complete", bytes.NewReader(raw))
+	if err != nil {
+		a.logger.Error("failed to report completion", "work_id", workID, "error", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// runWork writes item's spec to a scratch directory, runs builderBinary
+// against it, streams its output back line by line, uploads every
+// generated file, and reports the outcome.
+func (a *agent) runWork(item *agentrpc.WorkItem) {
+	buildDir, err := os.MkdirTemp(a.workDir, "agent-build-")
+	if err != nil {
+		a.logger.Error("failed to create build dir", "error", err)
+		a.complete(item.WorkID, -1, err.Error())
+		return
+	}
+	defer os.RemoveAll(buildDir)
+
+	specFile := filepath.Join(buildDir, "spec.json")
+	if err := os.WriteFile(specFile, item.SpecJSON, 0o644); err != nil {
+		a.complete(item.WorkID, -1, err.Error())
+		return
+	}
+
+	generatedDir := filepath.Join(buildDir, "generated")
+	workdirDir := filepath.Join(buildDir, "workdir")
+	if err := os.MkdirAll(generatedDir, 0o755); err != nil {
+		a.complete(item.WorkID, -1, err.Error())
+		return
+	}
+	if err := os.MkdirAll(workdirDir, 0o755); err != nil {
+		a.complete(item.WorkID, -1, err.Error())
+		return
+	}
+
+	cmd := exec.Command(a.builderBinary, "-s", specFile, generatedDir, workdirDir)
+	stdout, _ := cmd.StdoutPipe()
+	stderr, _ := cmd.StderrPipe()
+	if err := cmd.Start(); err != nil {
+		a.complete(item.WorkID, -1, err.Error())
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); a.streamOutput(item.WorkID, stdout) }()
+	go func() { defer wg.Done(); a.streamOutput(item.WorkID, stderr) }()
+	runErr := cmd.Wait()
+	wg.Wait()
+
+	exitCode := 0
+	errMsg := ""
+	if runErr != nil {
+		errMsg = runErr.Error()
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		} else {
+			exitCode = -1
+		}
+	}
+
+	if runErr == nil {
+		if err := a.uploadGenerated(item.WorkID, generatedDir); err != nil {
+			errMsg = fmt.Sprintf("upload artifacts: %v", err)
+		}
+	}
+
+	a.complete(item.WorkID, exitCode, errMsg)
+}
+
+func (a *agent) streamOutput(workID string, pipe io.ReadCloser) {
+	scanner := bufio.NewScanner(pipe)
+	for scanner.Scan() {
+		a.reportProgress(workID, scanner.Text())
+	}
+}
+
+// uploadGenerated walks dir and uploads every file it finds, relative to
+// dir, via uploadArtifact.
+func (a *agent) uploadGenerated(workID, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return a.uploadArtifact(workID, filepath.ToSlash(rel), info.Mode(), data)
+	})
+}
@@ -4,14 +4,30 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/Petr1Furious/potato-launcher/backend/internal/agentrpc"
 	"github.com/Petr1Furious/potato-launcher/backend/internal/api"
 	"github.com/Petr1Furious/potato-launcher/backend/internal/config"
 	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
 	"github.com/Petr1Furious/potato-launcher/backend/internal/services"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/download"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/jobs"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/snapshots"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/storage"
 	store "github.com/Petr1Furious/potato-launcher/backend/internal/storage"
 )
 
+func newStorageBackend(cfg *config.Config) (storage.Backend, error) {
+	switch cfg.StorageBackend {
+	case "s3":
+		return storage.NewS3Backend(cfg.StorageEndpoint, cfg.StorageBucket, cfg.StorageAccessKey, cfg.StorageSecretKey, cfg.StorageUseSSL)
+	default:
+		return storage.NewLocalBackend(cfg.UploadedInstancesDir), nil
+	}
+}
+
 func main() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
@@ -22,6 +38,8 @@ func main() {
 		os.Exit(1)
 	}
 
+	services.SetUpstreamCacheTTL(time.Duration(cfg.MCVersionsCacheTTLSeconds) * time.Second)
+
 	initialSpec := &models.BuilderSpec{
 		ReplaceDownloadURLs: cfg.ReplaceDownloadURLs,
 		Instances:           []models.BuilderInstance{},
@@ -33,17 +51,62 @@ func main() {
 		os.Exit(1)
 	}
 
+	backend, err := newStorageBackend(cfg)
+	if err != nil {
+		logger.Error("failed to init object storage backend", "error", err)
+		os.Exit(1)
+	}
+
 	authService := services.NewAuthService(cfg)
 	hub := services.NewHub(logger, authService)
 	go hub.Run()
 
+	microsoftAuth, err := services.NewMicrosoftAuthService(cfg.MicrosoftClientID, cfg.MicrosoftTokensFile)
+	if err != nil {
+		logger.Error("failed to init microsoft auth service", "error", err)
+		os.Exit(1)
+	}
+
+	oidcService := services.NewOIDCService()
+
+	runner := services.NewRunnerService(cfg, store, backend, logger, hub)
+
+	// In agent mode, builds are dispatched to remote agents (cmd/agent)
+	// instead of running instance_builder in this process.
+	var builder jobs.Builder = runner
+	var agentDispatcher *agentrpc.Dispatcher
+	if cfg.AgentMode {
+		agentDispatcher = agentrpc.NewDispatcher(cfg, store, backend, logger)
+		builder = agentDispatcher
+	}
+
+	jobLogsDir := filepath.Join(cfg.GeneratedDir, "logs")
+	jobManager, err := jobs.NewManager(cfg.JobsFile, builder, jobLogsDir, cfg.JobHistoryPerInstance, cfg.JobWorkerConcurrency, cfg.JobLogMaxBytes)
+	if err != nil {
+		logger.Error("failed to init job queue", "error", err)
+		os.Exit(1)
+	}
+
+	snapshotManager, err := snapshots.NewManager(cfg.SnapshotsDir, store, backend, cfg.SnapshotRetention)
+	if err != nil {
+		logger.Error("failed to init snapshot manager", "error", err)
+		os.Exit(1)
+	}
+
 	deps := &api.Dependencies{
-		Config: cfg,
-		Store:  store,
-		Auth:   authService,
-		Runner: services.NewRunnerService(cfg, store, logger, hub),
-		Hub:    hub,
-		Logger: logger,
+		Config:    cfg,
+		Store:     store,
+		Storage:   backend,
+		Downloads: download.New(cfg.TempDir),
+		Auth:      authService,
+		Runner:    runner,
+		Jobs:      jobManager,
+		Snapshots: snapshotManager,
+		Agent:     agentDispatcher,
+		Hub:       hub,
+		Microsoft: microsoftAuth,
+		OIDC:      oidcService,
+		Logger:    logger,
 	}
 
 	_, router := api.NewAPI(deps)
@@ -0,0 +1,230 @@
+package agentrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/config"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/storage"
+)
+
+// SpecProvider is the subset of storage.Store's API Dispatcher needs. It
+// mirrors services.SpecProvider; Dispatcher doesn't import services to
+// avoid a dependency from a control-plane-only package onto one that also
+// knows how to run instance_builder locally.
+type SpecProvider interface {
+	GetSpec() (*models.BuilderSpec, error)
+}
+
+// ExitCodeError reports a remote agent's instance_builder exit code. Jobs
+// whose Builder is a Dispatcher never produce a real *exec.ExitError (there
+// is no local process), so jobs.Manager's exit-code extraction also
+// recognizes this type; see jobs.exitCoder.
+type ExitCodeError struct {
+	Code int
+}
+
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("agent build exited with code %d", e.Code)
+}
+
+func (e *ExitCodeError) ExitCode() int { return e.Code }
+
+type pendingBuild struct {
+	out  io.Writer
+	done chan error
+}
+
+// Dispatcher hands BuildInstance work to whichever remote agent polls for
+// it next, instead of running instance_builder in-process. It satisfies
+// jobs.Builder, so jobs.Manager doesn't need to know whether a build runs
+// locally (services.RunnerService) or on a remote agent.
+type Dispatcher struct {
+	cfg     *config.Config
+	store   SpecProvider
+	backend storage.Backend
+	logger  *slog.Logger
+
+	queue chan *WorkItem
+
+	mu      sync.Mutex
+	pending map[string]*pendingBuild
+}
+
+// NewDispatcher creates a Dispatcher. Builds queue until an agent polls for
+// them; RunInstances blocks until an agent reports the build complete or
+// its context is canceled.
+func NewDispatcher(cfg *config.Config, store SpecProvider, backend storage.Backend, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		cfg:     cfg,
+		store:   store,
+		backend: backend,
+		logger:  logger,
+		queue:   make(chan *WorkItem, 64),
+		pending: make(map[string]*pendingBuild),
+	}
+}
+
+// RunInstances implements jobs.Builder by dispatching names to an agent
+// instead of invoking instance_builder locally.
+func (d *Dispatcher) RunInstances(ctx context.Context, names []string, out io.Writer) error {
+	spec, err := d.store.GetSpec()
+	if err != nil {
+		return err
+	}
+
+	builderSpec := models.BuilderSpec{
+		DownloadServerBase:  d.cfg.DownloadServerBase,
+		ResourcesURLBase:    d.cfg.ResourcesURLBase,
+		ReplaceDownloadURLs: spec.ReplaceDownloadURLs,
+		ExecBeforeAll:       d.cfg.ExecBeforeAll,
+		ExecAfterAll:        d.cfg.ExecAfterAll,
+		Instances:           filterInstances(spec.Instances, names),
+	}
+	specJSON, err := json.Marshal(builderSpec)
+	if err != nil {
+		return fmt.Errorf("encode spec for agent: %w", err)
+	}
+
+	item := &WorkItem{
+		WorkID:        uuid.NewString(),
+		InstanceNames: names,
+		SpecJSON:      specJSON,
+	}
+	done := make(chan error, 1)
+
+	d.mu.Lock()
+	d.pending[item.WorkID] = &pendingBuild{out: out, done: done}
+	d.mu.Unlock()
+
+	select {
+	case d.queue <- item:
+	case <-ctx.Done():
+		d.mu.Lock()
+		delete(d.pending, item.WorkID)
+		d.mu.Unlock()
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// The agent may still be working and call Complete later; we just
+		// stop waiting on it. jobs.Manager's worker moves on as soon as this
+		// call returns, so the next build can be dispatched while this agent
+		// is still running.
+		return ctx.Err()
+	}
+}
+
+// Poll blocks up to wait for a queued build, returning ok=false if none
+// arrives in time. Callers (internal/api's agent endpoints) should have the
+// agent call Poll again immediately after either outcome.
+func (d *Dispatcher) Poll(ctx context.Context, wait time.Duration) (*WorkItem, bool) {
+	select {
+	case item := <-d.queue:
+		return item, true
+	case <-time.After(wait):
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// ReportProgress forwards a log line from an agent into the job's own log
+// stream, the same io.Writer a locally-run build would have written to.
+func (d *Dispatcher) ReportProgress(workID, line string) error {
+	d.mu.Lock()
+	build, ok := d.pending[workID]
+	d.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown work item %q", workID)
+	}
+	if build.out != nil {
+		build.out.Write([]byte(line))
+	}
+	return nil
+}
+
+// UploadArtifact writes one generated file's bytes into the configured
+// object storage backend, under the same "<prefix>/<relpath>" keying
+// storage.Backend already uses for uploaded instance files.
+func (d *Dispatcher) UploadArtifact(ctx context.Context, chunk ArtifactChunk) error {
+	d.mu.Lock()
+	_, ok := d.pending[chunk.WorkID]
+	d.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown work item %q", chunk.WorkID)
+	}
+
+	mode := os.FileMode(chunk.Mode)
+	if mode == 0 {
+		mode = 0o644
+	}
+	rel, err := storage.SanitizeRelativePath(chunk.Path)
+	if err != nil {
+		return fmt.Errorf("invalid artifact path %q: %w", chunk.Path, err)
+	}
+	key := "generated/" + chunk.WorkID + "/" + rel
+	return d.backend.Put(ctx, key, bytes.NewReader(chunk.Data), int64(len(chunk.Data)), mode)
+}
+
+// Complete resolves the pending RunInstances call for workID with the
+// agent-reported outcome.
+func (d *Dispatcher) Complete(workID string, exitCode int, agentErr string) error {
+	d.mu.Lock()
+	build, ok := d.pending[workID]
+	if ok {
+		delete(d.pending, workID)
+	}
+	d.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown work item %q", workID)
+	}
+
+	var err error
+	switch {
+	case agentErr != "":
+		err = errors.New(agentErr)
+	case exitCode != 0:
+		err = &ExitCodeError{Code: exitCode}
+	}
+	build.done <- err
+	return nil
+}
+
+// filterInstances returns the subset of instances named in names,
+// preserving spec order. An empty/nil names selects every instance. This
+// mirrors services.RunnerService's unexported helper of the same name,
+// kept separate since the two packages don't otherwise share code.
+func filterInstances(instances []models.BuilderInstance, names []string) []models.BuilderInstance {
+	if len(names) == 0 {
+		out := make([]models.BuilderInstance, len(instances))
+		copy(out, instances)
+		return out
+	}
+
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+	out := make([]models.BuilderInstance, 0, len(names))
+	for _, instance := range instances {
+		if _, ok := wanted[instance.Name]; ok {
+			out = append(out, instance)
+		}
+	}
+	return out
+}
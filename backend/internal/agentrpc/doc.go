@@ -0,0 +1,10 @@
+// Package agentrpc coordinates build work between the backend and remote
+// build agents, so instance builds can run on workers with whatever OS/JDK
+// an instance needs instead of only on the machine running the backend.
+//
+// The protocol is a plain JSON-over-HTTP long-poll, not gRPC: an agent calls
+// Dispatcher.Poll (wired up by the raw handlers in internal/api/agent.go) to
+// block for its next WorkItem, reports progress and uploaded artifacts back
+// while it runs instance_builder, and finally calls Complete with the exit
+// outcome. The types in types.go are that exchange's wire format.
+package agentrpc
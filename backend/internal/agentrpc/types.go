@@ -0,0 +1,35 @@
+package agentrpc
+
+import "encoding/json"
+
+// WorkItem is a single build dispatched to an agent.
+type WorkItem struct {
+	WorkID        string          `json:"work_id"`
+	InstanceNames []string        `json:"instance_names,omitempty"`
+	SpecJSON      json.RawMessage `json:"spec_json"`
+}
+
+// ProgressUpdate is one log line reported by an agent while a work item is
+// running.
+type ProgressUpdate struct {
+	WorkID string `json:"work_id"`
+	Line   string `json:"line"`
+}
+
+// ArtifactChunk is one generated file reported by an agent. The HTTP
+// transport in internal/api/agent.go sends one file's full contents per
+// request (work_id/path/mode as query parameters, Data as the raw request
+// body) rather than splitting large files across calls.
+type ArtifactChunk struct {
+	WorkID string
+	Path   string
+	Mode   uint32
+	Data   []byte
+}
+
+// CompleteRequest reports a work item's terminal outcome.
+type CompleteRequest struct {
+	WorkID   string `json:"work_id"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/agentrpc"
+)
+
+// authenticateAgent validates the Authorization header as a build agent
+// token (never an admin session token, unlike ensureAuth) and returns the
+// agent's ID.
+func (d *Dependencies) authenticateAgent(r *http.Request) (string, bool) {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return "", false
+	}
+	agentID, err := d.Auth.ValidateAgentToken(parts[1])
+	if err != nil {
+		return "", false
+	}
+	return agentID, true
+}
+
+// handleAgentPoll long-polls for the next build dispatched to an agent,
+// implementing Agent.Poll (see internal/agentrpc). It responds 204 if nothing
+// arrives before the configured timeout, so the agent can just call it
+// again in a loop.
+func (d *Dependencies) handleAgentPoll(w http.ResponseWriter, r *http.Request) {
+	agentID, ok := d.authenticateAgent(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if d.Agent == nil {
+		http.Error(w, "agent mode is not enabled", http.StatusNotFound)
+		return
+	}
+
+	timeout := time.Duration(d.Config.AgentPollTimeoutSeconds) * time.Second
+	item, ok := d.Agent.Poll(r.Context(), timeout)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	d.Logger.Info("dispatched build to agent", "agent_id", agentID, "work_id", item.WorkID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// handleAgentProgress implements Agent.ReportProgress: one log line per
+// request, forwarded into the job's own log stream.
+func (d *Dependencies) handleAgentProgress(w http.ResponseWriter, r *http.Request) {
+	if _, ok := d.authenticateAgent(r); !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if d.Agent == nil {
+		http.Error(w, "agent mode is not enabled", http.StatusNotFound)
+		return
+	}
+
+	var update agentrpc.ProgressUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if err := d.Agent.ReportProgress(update.WorkID, update.Line); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAgentArtifact implements Agent.UploadArtifact: the request body is
+// one generated file's full contents, identified by work_id/path/mode query
+// parameters.
+func (d *Dependencies) handleAgentArtifact(w http.ResponseWriter, r *http.Request) {
+	if _, ok := d.authenticateAgent(r); !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if d.Agent == nil {
+		http.Error(w, "agent mode is not enabled", http.StatusNotFound)
+		return
+	}
+
+	workID := r.URL.Query().Get("work_id")
+	path := r.URL.Query().Get("path")
+	if workID == "" || path == "" {
+		http.Error(w, "work_id and path are required", http.StatusBadRequest)
+		return
+	}
+	mode, _ := strconv.ParseUint(r.URL.Query().Get("mode"), 8, 32)
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	chunk := agentrpc.ArtifactChunk{WorkID: workID, Path: path, Mode: uint32(mode), Data: data}
+	if err := d.Agent.UploadArtifact(r.Context(), chunk); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAgentComplete implements Agent.Complete: the agent's final report
+// of a work item's outcome.
+func (d *Dependencies) handleAgentComplete(w http.ResponseWriter, r *http.Request) {
+	if _, ok := d.authenticateAgent(r); !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if d.Agent == nil {
+		http.Error(w, "agent mode is not enabled", http.StatusNotFound)
+		return
+	}
+
+	var req agentrpc.CompleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if err := d.Agent.Complete(req.WorkID, req.ExitCode, req.Error); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// registerAgentTokens exposes the one admin operation needed to bootstrap a
+// build agent: minting it a token scoped to the agent endpoints above.
+func registerAgentTokens(api huma.API, deps *Dependencies) {
+	huma.Register(api, huma.Operation{
+		OperationID: "create-agent-token",
+		Method:      http.MethodPost,
+		Path:        "/agents/{id}/token",
+		Summary:     "Create Agent Token",
+		Description: "Mint a token a build agent can use to authenticate against /agent/poll, /agent/progress, /agent/artifact and /agent/complete.",
+		Tags:        []string{"Agents"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Token created successfully"},
+			"500": {Description: "Internal server error"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+		ID string `path:"id" doc:"Agent ID"`
+	}) (*struct {
+		Body TokenResponse
+	}, error) {
+		if err := deps.ensureAuth(input.Authorization); err != nil {
+			return nil, err
+		}
+		token, err := deps.Auth.CreateAgentToken(input.ID)
+		if err != nil {
+			deps.Logger.Error("failed to create agent token", "error", err)
+			return nil, huma.Error500InternalServerError("failed to sign token")
+		}
+		deps.Logger.Info("agent token created", "agent_id", input.ID)
+		return &struct{ Body TokenResponse }{Body: TokenResponse{AccessToken: token, TokenType: "bearer"}}, nil
+	})
+}
@@ -1,8 +1,11 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/danielgtaylor/huma/v2"
@@ -10,24 +13,40 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/Petr1Furious/potato-launcher/backend/internal/agentrpc"
 	"github.com/Petr1Furious/potato-launcher/backend/internal/config"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/metrics"
 	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
 	"github.com/Petr1Furious/potato-launcher/backend/internal/services"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/download"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/jobs"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/snapshots"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/storage"
 )
 
 type SpecStore interface {
-	GetSpec() (*models.Spec, error)
-	Update(func(*models.Spec) error) (*models.Spec, error)
+	GetSpec() (*models.BuilderSpec, error)
+	Update(func(*models.BuilderSpec) error) (*models.BuilderSpec, error)
 }
 
 type Dependencies struct {
-	Config *config.Config
-	Store  SpecStore
-	Auth   *services.AuthService
-	Runner *services.RunnerService
-	Hub    *services.Hub
-	Logger *slog.Logger
+	Config    *config.Config
+	Store     SpecStore
+	Storage   storage.Backend
+	Downloads *download.Coalescer
+	Auth      *services.AuthService
+	Runner    *services.RunnerService
+	Jobs      *jobs.Manager
+	Snapshots *snapshots.Manager
+	// Agent is nil unless Config.AgentMode is enabled, in which case builds
+	// are dispatched to remote agents instead of running locally via Runner.
+	Agent     *agentrpc.Dispatcher
+	Hub       *services.Hub
+	Microsoft *services.MicrosoftAuthService
+	OIDC      *services.OIDCService
+	Logger    *slog.Logger
 }
 
 func NewAPI(deps *Dependencies) (huma.API, chi.Router) {
@@ -40,8 +59,19 @@ func NewAPI(deps *Dependencies) (huma.API, chi.Router) {
 
 	apiRouter := chi.NewRouter()
 	root.Mount("/api/v1", apiRouter)
+	root.Handle("/metrics", promhttp.Handler())
 
 	apiRouter.Get("/ws", deps.Hub.HandleWebSocket)
+	apiRouter.Get("/instances/build/logs", deps.handleLatestBuildLogsSSE)
+	apiRouter.Get("/jobs/{id}/logs", deps.handleJobLogsSSE)
+	apiRouter.Get("/jobs/{id}/logs/download", deps.handleJobLogsDownload)
+	apiRouter.Post("/agent/poll", deps.handleAgentPoll)
+	apiRouter.Post("/agent/progress", deps.handleAgentProgress)
+	apiRouter.Post("/agent/artifact", deps.handleAgentArtifact)
+	apiRouter.Post("/agent/complete", deps.handleAgentComplete)
+	apiRouter.Get("/launchers/{os}/{artifact}", deps.handleDownloadLauncherArtifact)
+	apiRouter.Get("/launchers/{os}/{artifact}/version", deps.handleGetLauncherVersion)
+	apiRouter.Post("/launchers/{os}/{artifact}", deps.handleUploadLauncher)
 
 	cfg := huma.DefaultConfig("Potato Launcher Backend", "1.0.0")
 	cfg.OpenAPIPath = "/openapi"
@@ -81,8 +111,205 @@ func NewAPI(deps *Dependencies) (huma.API, chi.Router) {
 			},
 		},
 	}
+	logStreamParams := []*huma.Param{
+		{
+			Name:        "tail",
+			In:          "query",
+			Description: "Replay only the last N buffered lines instead of the whole ring buffer",
+			Schema:      &huma.Schema{Type: "integer"},
+		},
+		{
+			Name:        "token",
+			In:          "query",
+			Description: "Access token, for clients that can't set an Authorization header",
+			Schema:      &huma.Schema{Type: "string"},
+		},
+	}
+	cfg.OpenAPI.Paths["/instances/build/logs"] = &huma.PathItem{
+		Get: &huma.Operation{
+			OperationID: "stream-latest-build-logs",
+			Summary:     "Stream Latest Build Logs",
+			Description: "Server-Sent Events stream of the most recently triggered build job's log, with a ?tail=N replay for late subscribers.",
+			Tags:        []string{"Instances"},
+			Parameters:  logStreamParams,
+			Responses: map[string]*huma.Response{
+				"200": {Description: "text/event-stream of log lines"},
+				"401": {Description: "Unauthorized"},
+				"404": {Description: "No build has been triggered yet"},
+			},
+		},
+	}
+	cfg.OpenAPI.Paths["/jobs/{id}/logs"] = &huma.PathItem{
+		Get: &huma.Operation{
+			OperationID: "stream-job-logs",
+			Summary:     "Stream Job Logs",
+			Description: "Server-Sent Events stream of a job's build log, with a ?tail=N replay for late subscribers. Finished jobs replay their flushed log file and close the stream.",
+			Tags:        []string{"Jobs"},
+			Parameters: append([]*huma.Param{
+				{
+					Name:     "id",
+					In:       "path",
+					Required: true,
+					Schema:   &huma.Schema{Type: "string"},
+				},
+			}, logStreamParams...),
+			Responses: map[string]*huma.Response{
+				"200": {Description: "text/event-stream of log lines"},
+				"401": {Description: "Unauthorized"},
+				"404": {Description: "Job logs not found"},
+			},
+		},
+	}
+	cfg.OpenAPI.Paths["/jobs/{id}/logs/download"] = &huma.PathItem{
+		Get: &huma.Operation{
+			OperationID: "download-job-logs",
+			Summary:     "Download Job Logs",
+			Description: "Download a finished job's flushed log file.",
+			Tags:        []string{"Jobs"},
+			Parameters: []*huma.Param{
+				{
+					Name:     "id",
+					In:       "path",
+					Required: true,
+					Schema:   &huma.Schema{Type: "string"},
+				},
+				{
+					Name:        "token",
+					In:          "query",
+					Description: "Access token, for clients that can't set an Authorization header",
+					Schema:      &huma.Schema{Type: "string"},
+				},
+			},
+			Responses: map[string]*huma.Response{
+				"200": {Description: "Log file"},
+				"401": {Description: "Unauthorized"},
+				"404": {Description: "Job log not available yet"},
+			},
+		},
+	}
+
+	cfg.OpenAPI.Paths["/agent/poll"] = &huma.PathItem{
+		Post: &huma.Operation{
+			OperationID: "agent-poll",
+			Summary:     "Agent Poll",
+			Description: "Long-poll for the next build dispatched to an agent (Agent.Poll). Requires an agent token, not the admin session token.",
+			Tags:        []string{"Agents"},
+			Responses: map[string]*huma.Response{
+				"200": {Description: "A build work item"},
+				"204": {Description: "No work before the poll timeout"},
+				"401": {Description: "Unauthorized"},
+				"404": {Description: "Agent mode is not enabled"},
+			},
+		},
+	}
+	cfg.OpenAPI.Paths["/agent/progress"] = &huma.PathItem{
+		Post: &huma.Operation{
+			OperationID: "agent-report-progress",
+			Summary:     "Agent Report Progress",
+			Description: "Report one log line for a work item (Agent.ReportProgress).",
+			Tags:        []string{"Agents"},
+			Responses: map[string]*huma.Response{
+				"204": {Description: "Accepted"},
+				"401": {Description: "Unauthorized"},
+				"404": {Description: "Unknown work item or agent mode is not enabled"},
+			},
+		},
+	}
+	cfg.OpenAPI.Paths["/agent/artifact"] = &huma.PathItem{
+		Post: &huma.Operation{
+			OperationID: "agent-upload-artifact",
+			Summary:     "Agent Upload Artifact",
+			Description: "Upload one generated file's contents for a work item (Agent.UploadArtifact). work_id/path/mode are query parameters; the request body is the file's raw bytes.",
+			Tags:        []string{"Agents"},
+			Parameters: []*huma.Param{
+				{Name: "work_id", In: "query", Required: true, Schema: &huma.Schema{Type: "string"}},
+				{Name: "path", In: "query", Required: true, Schema: &huma.Schema{Type: "string"}},
+				{Name: "mode", In: "query", Schema: &huma.Schema{Type: "string"}, Description: "Octal file mode"},
+			},
+			Responses: map[string]*huma.Response{
+				"204": {Description: "Accepted"},
+				"401": {Description: "Unauthorized"},
+				"404": {Description: "Unknown work item or agent mode is not enabled"},
+			},
+		},
+	}
+	cfg.OpenAPI.Paths["/launchers/{os}/{artifact}"] = &huma.PathItem{
+		Get: &huma.Operation{
+			OperationID: "get-launcher-artifact",
+			Summary:     "Download launcher artifact",
+			Description: "Download launcher artifact for the given OS and artifact type, following ?channel= (default stable) or pinned to ?version=. When redirect downloads are enabled, responds with a 302 to a public or presigned URL instead of streaming the bytes through this process; pass ?redirect=false to force a direct stream. Supports conditional GET (If-None-Match/If-Modified-Since) and Range requests.",
+			Tags:        []string{"Launchers"},
+			Parameters: []*huma.Param{
+				{Name: "os", In: "path", Required: true, Schema: &huma.Schema{Type: "string", Enum: []interface{}{"windows", "macos", "linux"}}},
+				{Name: "artifact", In: "path", Required: true, Schema: &huma.Schema{Type: "string", Enum: []interface{}{"exe", "dmg", "archive", "bin", "flatpak", "flatpakref"}}},
+				{Name: "channel", In: "query", Description: "Release channel to follow, e.g. stable, beta, nightly; defaults to stable", Schema: &huma.Schema{Type: "string"}},
+				{Name: "version", In: "query", Description: "Pin to a specific historical version instead of the channel's latest", Schema: &huma.Schema{Type: "string"}},
+				{Name: "redirect", In: "query", Description: "Override RedirectDownloads; set to false to force a direct stream", Schema: &huma.Schema{Type: "boolean"}},
+			},
+			Responses: map[string]*huma.Response{
+				"200": {Description: "Artifact bytes"},
+				"206": {Description: "Partial content for a satisfiable Range request"},
+				"302": {Description: "Redirect to a public or presigned URL"},
+				"304": {Description: "Not modified, per If-None-Match/If-Modified-Since"},
+				"400": {Description: "Invalid os/artifact"},
+				"404": {Description: "Artifact not uploaded"},
+			},
+		},
+		Post: &huma.Operation{
+			OperationID: "upload-launcher",
+			Summary:     "Upload launcher artifact",
+			Description: "Upload launcher artifact for an OS/artifact pair. The request body is streamed to a temp file and hashed while it's written, rather than buffered whole in memory. ?version= identifies the build (e.g. a git sha) and a token may be passed as Authorization header or ?token= query parameter.",
+			Tags:        []string{"Launchers"},
+			Parameters: []*huma.Param{
+				{Name: "os", In: "path", Required: true, Schema: &huma.Schema{Type: "string", Enum: []interface{}{"windows", "macos", "linux"}}},
+				{Name: "artifact", In: "path", Required: true, Schema: &huma.Schema{Type: "string", Enum: []interface{}{"exe", "dmg", "archive", "bin", "flatpak", "flatpakref"}}},
+				{Name: "version", In: "query", Required: true, Description: "Launcher version identifier (e.g. git sha)", Schema: &huma.Schema{Type: "string"}},
+				{Name: "channel", In: "query", Description: "Release channel (e.g. stable, beta, nightly); defaults to stable", Schema: &huma.Schema{Type: "string"}},
+				{Name: "pin", In: "query", Description: "Exempt this version from retention cleanup", Schema: &huma.Schema{Type: "boolean"}},
+				{Name: "token", In: "query", Description: "Access token, for clients that can't set an Authorization header", Schema: &huma.Schema{Type: "string"}},
+			},
+			Responses: map[string]*huma.Response{
+				"204": {Description: "Uploaded successfully"},
+				"400": {Description: "Invalid os/artifact, missing version, or empty upload"},
+				"401": {Description: "Unauthorized"},
+			},
+		},
+	}
+	cfg.OpenAPI.Paths["/launchers/{os}/{artifact}/version"] = &huma.PathItem{
+		Get: &huma.Operation{
+			OperationID: "get-launcher-version",
+			Summary:     "Get launcher artifact version",
+			Description: "Return the version string for the latest uploaded launcher artifact in a channel (default stable). Supports conditional GET (If-None-Match/If-Modified-Since).",
+			Tags:        []string{"Launchers"},
+			Parameters: []*huma.Param{
+				{Name: "os", In: "path", Required: true, Schema: &huma.Schema{Type: "string", Enum: []interface{}{"windows", "macos", "linux"}}},
+				{Name: "artifact", In: "path", Required: true, Schema: &huma.Schema{Type: "string", Enum: []interface{}{"exe", "dmg", "archive", "bin", "flatpak", "flatpakref"}}},
+				{Name: "channel", In: "query", Description: "Release channel to follow, e.g. stable, beta, nightly; defaults to stable", Schema: &huma.Schema{Type: "string"}},
+			},
+			Responses: map[string]*huma.Response{
+				"200": {Description: "Plain-text version string"},
+				"304": {Description: "Not modified, per If-None-Match/If-Modified-Since"},
+				"400": {Description: "Invalid os/artifact"},
+				"404": {Description: "Artifact not uploaded"},
+			},
+		},
+	}
+	cfg.OpenAPI.Paths["/agent/complete"] = &huma.PathItem{
+		Post: &huma.Operation{
+			OperationID: "agent-complete",
+			Summary:     "Agent Complete",
+			Description: "Report a work item's terminal outcome (Agent.Complete).",
+			Tags:        []string{"Agents"},
+			Responses: map[string]*huma.Response{
+				"204": {Description: "Accepted"},
+				"401": {Description: "Unauthorized"},
+				"404": {Description: "Unknown work item or agent mode is not enabled"},
+			},
+		},
+	}
 
 	api := humachi.New(apiRouter, cfg)
+	api.UseMiddleware(metricsMiddleware)
 
 	apiRouter.Get("/docs", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
@@ -111,13 +338,31 @@ func NewAPI(deps *Dependencies) (huma.API, chi.Router) {
 	})
 
 	registerAuth(api, deps)
+	registerAuthCheck(api, deps)
+	registerMe(api, deps)
+	registerMicrosoftAuth(api, deps)
 	registerSettings(api, deps)
 	registerInstances(api, deps)
+	registerJobs(api, deps)
+	registerSnapshots(api, deps)
+	registerAgentTokens(api, deps)
 	registerMCVersions(api, deps)
+	registerLaunchers(api, deps)
+	registerOIDCAuthBackends(api, deps)
+	registerHealth(api, deps)
 
 	return api, root
 }
 
+// metricsMiddleware records every request huma handles on
+// http_requests_total, labeled by the OperationID set on its huma.Operation
+// (e.g. "list-mc-versions") rather than the raw path, so path parameters
+// don't explode the label cardinality.
+func metricsMiddleware(ctx huma.Context, next func(huma.Context)) {
+	next(ctx)
+	metrics.HTTPRequestsTotal.WithLabelValues(ctx.Operation().OperationID, strconv.Itoa(ctx.Status())).Inc()
+}
+
 type AuthHeaders struct {
 	Authorization string `header:"Authorization" hidden:"true"`
 }
@@ -130,9 +375,83 @@ func (d *Dependencies) ensureAuth(header string) error {
 	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
 		return huma.Error401Unauthorized("expected Bearer token")
 	}
-	if _, err := d.Auth.ValidateToken(parts[1]); err != nil {
+	if _, err := d.Auth.ValidateAdminToken(parts[1]); err != nil {
 		d.Logger.Warn("invalid token attempt", "error", err)
 		return huma.Error401Unauthorized("invalid token")
 	}
 	return nil
 }
+
+// authenticatedClaims validates header as an admin bearer token and returns
+// its decoded claims, for handlers that need the caller's roles (e.g. /me,
+// ensureRole) rather than just a yes/no check.
+func (d *Dependencies) authenticatedClaims(header string) (*services.JWTClaims, error) {
+	if header == "" {
+		return nil, huma.Error401Unauthorized("missing Authorization header")
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return nil, huma.Error401Unauthorized("expected Bearer token")
+	}
+	claims, err := d.Auth.ValidateAdminToken(parts[1])
+	if err != nil {
+		d.Logger.Warn("invalid token attempt", "error", err)
+		return nil, huma.Error401Unauthorized("invalid token")
+	}
+	return claims, nil
+}
+
+// ensureRole validates header as an admin bearer token and requires that its
+// claims carry at least one of roles, returning a 403 AppError (via
+// mapAppError) rather than huma.Error401Unauthorized once the token itself
+// is valid but under-privileged.
+func (d *Dependencies) ensureRole(header string, roles ...string) error {
+	claims, err := d.authenticatedClaims(header)
+	if err != nil {
+		return err
+	}
+	if !hasAnyRole(claims.Roles, roles) {
+		return mapAppError(NewForbiddenError(fmt.Sprintf("requires one of roles: %s", strings.Join(roles, ", "))))
+	}
+	return nil
+}
+
+func hasAnyRole(have, want []string) bool {
+	if len(want) == 0 {
+		return true
+	}
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequireRoles builds a Huma middleware enforcing ensureRole on an
+// operation, and is meant to be set on its huma.Operation.Middlewares
+// alongside Extensions: requiredRoleExtensions(roles...), so the generated
+// OpenAPI advertises which roles it needs via "x-required-roles".
+func (d *Dependencies) RequireRoles(api huma.API, roles ...string) huma.Middlewares {
+	return huma.Middlewares{func(ctx huma.Context, next func(huma.Context)) {
+		if err := d.ensureRole(ctx.Header("Authorization"), roles...); err != nil {
+			var statusErr huma.StatusError
+			if errors.As(err, &statusErr) {
+				huma.WriteErr(api, ctx, statusErr.GetStatus(), statusErr.Error())
+			} else {
+				huma.WriteErr(api, ctx, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+		next(ctx)
+	}}
+}
+
+// requiredRoleExtensions is the OpenAPI extension advertising which roles an
+// operation using RequireRoles requires, for the launcher UI and API
+// consumers to introspect without trial and error.
+func requiredRoleExtensions(roles ...string) map[string]any {
+	return map[string]any{"x-required-roles": roles}
+}
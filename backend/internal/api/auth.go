@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/danielgtaylor/huma/v2"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services"
 )
 
 func registerAuth(api huma.API, deps *Dependencies) {
@@ -24,7 +26,7 @@ func registerAuth(api huma.API, deps *Dependencies) {
 			deps.Logger.Warn("login failed: invalid admin token")
 			return nil, huma.Error401Unauthorized("invalid token")
 		}
-		token, err := deps.Auth.CreateAccessToken("single_user")
+		token, err := deps.Auth.CreateAccessToken("single_user", []string{services.RoleAdmin, services.RoleBuilder, services.RoleViewer})
 		if err != nil {
 			deps.Logger.Error("failed to create access token", "error", err)
 			return nil, huma.Error500InternalServerError("failed to sign token")
@@ -41,6 +43,38 @@ func registerAuth(api huma.API, deps *Dependencies) {
 	})
 }
 
+// MeResponse is the caller's decoded roles, so the launcher UI can hide or
+// show admin controls without guessing from which requests happen to
+// succeed.
+type MeResponse struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+}
+
+func registerMe(api huma.API, deps *Dependencies) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-me",
+		Method:      http.MethodGet,
+		Path:        "/me",
+		Summary:     "Get current session",
+		Description: "Get the roles granted to the caller's admin session token.",
+		Tags:        []string{"Authorization"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+	}) (*struct {
+		Body MeResponse
+	}, error) {
+		claims, err := deps.authenticatedClaims(input.Authorization)
+		if err != nil {
+			return nil, err
+		}
+		return &struct {
+			Body MeResponse
+		}{Body: MeResponse{Subject: claims.Subject, Roles: claims.Roles}}, nil
+	})
+}
+
 func registerAuthCheck(api huma.API, deps *Dependencies) {
 	huma.Register(api, huma.Operation{
 		OperationID: "auth-check",
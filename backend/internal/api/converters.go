@@ -1,14 +1,19 @@
 package api
 
 import (
-	"os"
-	"path/filepath"
+	"context"
+	"fmt"
 	"strings"
 
-	"github.com/Petr1Furious/potato-launcher/backend/internal/config"
 	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/jobs"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/snapshots"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/storage"
 )
 
+const defaultOIDCUsernameClaim = "preferred_username"
+
 func toAPISettings(spec *models.BuilderSpec) APISettings {
 	return APISettings{
 		ReplaceDownloadURLs: spec.ReplaceDownloadURLs,
@@ -31,17 +36,14 @@ func toAPIInstance(v models.BuilderInstance) APIInstance {
 	}
 }
 
-func getInstanceDir(cfg *config.Config, instanceName string) string {
-	return filepath.Join(cfg.UploadedInstancesDir, instanceName)
-}
-
-func ensureIncludeFrom(cfg *config.Config, instance *models.BuilderInstance) {
-	instance.IncludeFrom = filepath.ToSlash(getInstanceDir(cfg, instance.Name))
+// instanceKeyPrefix returns the storage key under which an instance's
+// uploaded files live: "<instance>/<relpath>".
+func instanceKeyPrefix(instanceName string) string {
+	return instanceName + "/"
 }
 
-func ensureInstanceDir(cfg *config.Config, instanceName string) error {
-	dir := getInstanceDir(cfg, instanceName)
-	return os.MkdirAll(dir, 0o755)
+func ensureIncludeFrom(backend storage.Backend, instance *models.BuilderInstance) {
+	instance.IncludeFrom = backend.URI(instanceKeyPrefix(instance.Name))
 }
 
 func ensureAuthBackend(instance *models.BuilderInstance) {
@@ -50,7 +52,40 @@ func ensureAuthBackend(instance *models.BuilderInstance) {
 	}
 }
 
-func normalizeInstance(cfg *config.Config, instance *models.BuilderInstance) error {
+// validateOIDCBackend fills in OIDC defaults and, at builder-config
+// validation time, hits the issuer's .well-known/openid-configuration to
+// confirm it's reachable and well-formed. The discovered provider (and its
+// JWKS) is cached by oidcSvc for reuse by the oidc-config descriptor
+// endpoint.
+func validateOIDCBackend(ctx context.Context, oidcSvc *services.OIDCService, backend *models.AuthBackend) error {
+	backend.IssuerURL = strings.TrimSpace(backend.IssuerURL)
+	if backend.IssuerURL == "" {
+		return NewValidationError("auth_backend.issuer_url", "issuer_url is required for oidc auth backend")
+	}
+	if backend.UsernameClaim == "" {
+		backend.UsernameClaim = defaultOIDCUsernameClaim
+	}
+	if len(backend.Scopes) == 0 {
+		backend.Scopes = []string{"openid", "profile"}
+	}
+
+	if _, err := oidcSvc.Discover(ctx, backend.IssuerURL); err != nil {
+		return NewValidationError("auth_backend.issuer_url", err.Error())
+	}
+	return nil
+}
+
+// validateMicrosoftBackend confirms Microsoft auth is actually usable before
+// an instance is allowed to declare it as its auth backend, the same way
+// validateOIDCBackend confirms an OIDC issuer is reachable.
+func validateMicrosoftBackend(microsoftSvc *services.MicrosoftAuthService) error {
+	if !microsoftSvc.Configured() {
+		return NewValidationError("auth_backend.type", "microsoft auth backend requires MICROSOFT_CLIENT_ID to be configured")
+	}
+	return nil
+}
+
+func normalizeInstance(ctx context.Context, backend storage.Backend, oidcSvc *services.OIDCService, microsoftSvc *services.MicrosoftAuthService, instance *models.BuilderInstance) error {
 	instance.Name = strings.TrimSpace(instance.Name)
 	if instance.Name == "" {
 		return NewValidationError("name", "name is required")
@@ -62,16 +97,61 @@ func normalizeInstance(cfg *config.Config, instance *models.BuilderInstance) err
 	if instance.LoaderName == "" {
 		instance.LoaderName = models.LoaderVanilla
 	}
+	if !services.IsRegisteredLoader(instance.LoaderName) {
+		registered := services.RegisteredLoaders()
+		names := make([]string, len(registered))
+		for i, loader := range registered {
+			names[i] = string(loader)
+		}
+		return NewValidationError("loader_name", fmt.Sprintf("unknown loader %q, must be one of: %s", instance.LoaderName, strings.Join(names, ", ")))
+	}
 	if instance.LoaderName != models.LoaderVanilla && strings.TrimSpace(instance.LoaderVersion) == "" {
 		return NewValidationError("loader_version", "loader_version is required")
 	}
 
-	ensureIncludeFrom(cfg, instance)
+	ensureIncludeFrom(backend, instance)
 	ensureAuthBackend(instance)
+	if instance.AuthBackend.Type == models.AuthOIDC {
+		if err := validateOIDCBackend(ctx, oidcSvc, instance.AuthBackend); err != nil {
+			return err
+		}
+	}
+	if instance.AuthBackend.Type == models.AuthMicrosoft {
+		if err := validateMicrosoftBackend(microsoftSvc); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func toBuilderInstance(cfg *config.Config, m APIInstance) (*models.BuilderInstance, error) {
+func toSnapshotResponse(s *snapshots.Snapshot) SnapshotResponse {
+	return SnapshotResponse{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		Size:      s.Size,
+		SHA256:    s.SHA256,
+		Automatic: s.Automatic,
+		WithDirs:  s.WithDirs,
+	}
+}
+
+func toJobResponse(j *jobs.Job) JobResponse {
+	return JobResponse{
+		ID:         j.ID,
+		Kind:       j.Kind,
+		Instance:   j.Instance,
+		ParentID:   j.ParentID,
+		Status:     j.Status,
+		ExitCode:   j.ExitCode,
+		Error:      j.Error,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+		DurationMs: j.Duration().Milliseconds(),
+	}
+}
+
+func toBuilderInstance(ctx context.Context, backend storage.Backend, oidcSvc *services.OIDCService, microsoftSvc *services.MicrosoftAuthService, m APIInstance) (*models.BuilderInstance, error) {
 	instance := models.BuilderInstance{
 		Name:             m.Name,
 		MinecraftVersion: m.MinecraftVersion,
@@ -81,7 +161,7 @@ func toBuilderInstance(cfg *config.Config, m APIInstance) (*models.BuilderInstan
 		Include:          m.Include,
 		AuthBackend:      m.AuthBackend,
 	}
-	if err := normalizeInstance(cfg, &instance); err != nil {
+	if err := normalizeInstance(ctx, backend, oidcSvc, microsoftSvc, &instance); err != nil {
 		return nil, err
 	}
 	return &instance, nil
@@ -7,9 +7,11 @@ import (
 type ErrorCode string
 
 const (
-	ErrCodeValidation ErrorCode = "validation_error"
-	ErrCodeConflict   ErrorCode = "conflict"
-	ErrCodeNotFound   ErrorCode = "not_found"
+	ErrCodeValidation  ErrorCode = "validation_error"
+	ErrCodeConflict    ErrorCode = "conflict"
+	ErrCodeNotFound    ErrorCode = "not_found"
+	ErrCodeXboxAccount ErrorCode = "xbox_account_error"
+	ErrCodeForbidden   ErrorCode = "forbidden"
 )
 
 type AppError struct {
@@ -46,3 +48,17 @@ func NewNotFoundError(message string) *AppError {
 		Message: message,
 	}
 }
+
+func NewXboxAccountError(message string) *AppError {
+	return &AppError{
+		Code:    ErrCodeXboxAccount,
+		Message: message,
+	}
+}
+
+func NewForbiddenError(message string) *AppError {
+	return &AppError{
+		Code:    ErrCodeForbidden,
+		Message: message,
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services"
+)
+
+// UpstreamsHealthResponse reports the current circuit breaker state
+// ("closed", "open", "half_open") of every upstream Minecraft version API.
+type UpstreamsHealthResponse struct {
+	Breakers map[string]string `json:"breakers"`
+}
+
+func registerHealth(api huma.API, deps *Dependencies) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-upstreams-health",
+		Method:      http.MethodGet,
+		Path:        "/health/upstreams",
+		Summary:     "Get Upstream Health",
+		Description: "Get the circuit breaker state of each upstream Minecraft version API (Mojang, Fabric, Quilt, Forge, NeoForge).",
+		Tags:        []string{"Health"},
+	}, func(ctx context.Context, input *struct{}) (*struct {
+		Body UpstreamsHealthResponse
+	}, error) {
+		return &struct {
+			Body UpstreamsHealthResponse
+		}{Body: UpstreamsHealthResponse{Breakers: services.UpstreamBreakerStates()}}, nil
+	})
+}
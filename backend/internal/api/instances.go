@@ -3,14 +3,20 @@ package api
 import (
 	"context"
 	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/danielgtaylor/huma/v2"
 
-	"github.com/Petr1Furious/potato-launcher/backend/internal/config"
 	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/storage"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/upload"
 )
 
 var (
@@ -34,7 +40,7 @@ func registerInstances(api huma.API, deps *Dependencies) {
 	}, func(ctx context.Context, input *struct {
 		AuthHeaders
 	}) (*struct {
-		Body []Instance
+		Body []APIInstance
 	}, error) {
 		if err := deps.ensureAuth(input.Authorization); err != nil {
 			return nil, err
@@ -44,11 +50,11 @@ func registerInstances(api huma.API, deps *Dependencies) {
 			return nil, huma.Error500InternalServerError(err.Error())
 		}
 
-		instances := make([]Instance, len(spec.Versions))
-		for i, v := range spec.Versions {
+		instances := make([]APIInstance, len(spec.Instances))
+		for i, v := range spec.Instances {
 			instances[i] = toAPIInstance(v)
 		}
-		return &struct{ Body []Instance }{Body: instances}, nil
+		return &struct{ Body []APIInstance }{Body: instances}, nil
 	})
 
 	huma.Register(api, huma.Operation{
@@ -59,6 +65,8 @@ func registerInstances(api huma.API, deps *Dependencies) {
 		Description: "Create a new instance configuration.",
 		Tags:        []string{"Instances"},
 		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Extensions:  requiredRoleExtensions(services.RoleAdmin),
+		Middlewares: deps.RequireRoles(api, services.RoleAdmin),
 		Responses: map[string]*huma.Response{
 			"200": {Description: "Instance created successfully"},
 			"409": {Description: "Instance already exists"},
@@ -67,36 +75,32 @@ func registerInstances(api huma.API, deps *Dependencies) {
 		},
 	}, func(ctx context.Context, input *struct {
 		AuthHeaders
-		Body Instance
+		Body APIInstance
 	}) (*struct {
-		Body Instance
+		Body APIInstance
 	}, error) {
-		if err := deps.ensureAuth(input.Authorization); err != nil {
-			return nil, err
-		}
-
-		version := toModelInstance(input.Body)
-		if err := normalizeVersion(deps.Config, &version); err != nil {
+		instance, err := toBuilderInstance(ctx, deps.Storage, deps.OIDC, deps.Microsoft, input.Body)
+		if err != nil {
 			return nil, mapAppError(err)
 		}
 
-		updated, err := deps.Store.Update(func(spec *models.Spec) error {
-			if idx := versionIndex(spec, version.Name); idx != -1 {
+		updated, err := deps.Store.Update(func(spec *models.BuilderSpec) error {
+			if idx := instanceIndex(spec, instance.Name); idx != -1 {
 				return errInstanceExists
 			}
-			spec.Versions = append(spec.Versions, version)
+			spec.Instances = append(spec.Instances, *instance)
 			return nil
 		})
 		if err != nil {
 			return nil, mapAppError(err)
 		}
 
-		_, created := findVersion(updated, version.Name)
+		_, created := findInstance(updated, instance.Name)
 		if created == nil {
 			return nil, huma.Error500InternalServerError("failed to create instance")
 		}
-		deps.Logger.Info("instance created", "name", version.Name)
-		return &struct{ Body Instance }{Body: toAPIInstance(*created)}, nil
+		deps.Logger.Info("instance created", "name", instance.Name)
+		return &struct{ Body APIInstance }{Body: toAPIInstance(*created)}, nil
 	})
 
 	huma.Register(api, huma.Operation{
@@ -116,7 +120,7 @@ func registerInstances(api huma.API, deps *Dependencies) {
 		AuthHeaders
 		Name string `path:"name" doc:"Instance name"`
 	}) (*struct {
-		Body Instance
+		Body APIInstance
 	}, error) {
 		if err := deps.ensureAuth(input.Authorization); err != nil {
 			return nil, err
@@ -125,11 +129,11 @@ func registerInstances(api huma.API, deps *Dependencies) {
 		if err != nil {
 			return nil, huma.Error500InternalServerError(err.Error())
 		}
-		_, version := findVersion(spec, input.Name)
-		if version == nil {
+		_, instance := findInstance(spec, input.Name)
+		if instance == nil {
 			return nil, huma.Error404NotFound("instance not found")
 		}
-		return &struct{ Body Instance }{Body: toAPIInstance(*version)}, nil
+		return &struct{ Body APIInstance }{Body: toAPIInstance(*instance)}, nil
 	})
 
 	huma.Register(api, huma.Operation{
@@ -140,6 +144,8 @@ func registerInstances(api huma.API, deps *Dependencies) {
 		Description: "Update an existing instance configuration.",
 		Tags:        []string{"Instances"},
 		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Extensions:  requiredRoleExtensions(services.RoleAdmin),
+		Middlewares: deps.RequireRoles(api, services.RoleAdmin),
 		Responses: map[string]*huma.Response{
 			"200": {Description: "Instance updated successfully"},
 			"404": {Description: "Instance not found"},
@@ -150,42 +156,37 @@ func registerInstances(api huma.API, deps *Dependencies) {
 	}, func(ctx context.Context, input *struct {
 		AuthHeaders
 		Name string `path:"name" doc:"Instance name"`
-		Body Instance
+		Body APIInstance
 	}) (*struct {
-		Body Instance
+		Body APIInstance
 	}, error) {
-		if err := deps.ensureAuth(input.Authorization); err != nil {
-			return nil, err
-		}
-
-		newVersion := toModelInstance(input.Body)
-		if strings.TrimSpace(newVersion.Name) == "" {
-			newVersion.Name = input.Name
+		if strings.TrimSpace(input.Body.Name) == "" {
+			input.Body.Name = input.Name
 		}
 
-		updated, err := deps.Store.Update(func(spec *models.Spec) error {
-			idx, existing := findVersion(spec, input.Name)
+		var newName string
+		updated, err := deps.Store.Update(func(spec *models.BuilderSpec) error {
+			idx, existing := findInstance(spec, input.Name)
 			if idx == -1 {
 				return errInstanceNotFound
 			}
 
-			if newVersion.Name != input.Name {
-				if other := versionIndex(spec, newVersion.Name); other != -1 {
-					return errInstanceExists
-				}
+			instance, err := toBuilderInstance(ctx, deps.Storage, deps.OIDC, deps.Microsoft, input.Body)
+			if err != nil {
+				return err
 			}
 
-			newVersion.ExecBefore = existing.ExecBefore
-			newVersion.ExecAfter = existing.ExecAfter
-			if newVersion.IncludeFrom == "" {
-				newVersion.IncludeFrom = existing.IncludeFrom
+			if instance.Name != input.Name {
+				if other := instanceIndex(spec, instance.Name); other != -1 {
+					return errInstanceExists
+				}
 			}
 
-			if err := normalizeVersion(deps.Config, &newVersion); err != nil {
-				return err
-			}
+			instance.ExecBefore = existing.ExecBefore
+			instance.ExecAfter = existing.ExecAfter
 
-			spec.Versions[idx] = newVersion
+			spec.Instances[idx] = *instance
+			newName = instance.Name
 			return nil
 		})
 
@@ -193,9 +194,9 @@ func registerInstances(api huma.API, deps *Dependencies) {
 			return nil, mapAppError(err)
 		}
 
-		_, current := findVersion(updated, newVersion.Name)
-		deps.Logger.Info("instance updated", "name", input.Name, "new_name", newVersion.Name)
-		return &struct{ Body Instance }{Body: toAPIInstance(*current)}, nil
+		_, current := findInstance(updated, newName)
+		deps.Logger.Info("instance updated", "name", input.Name, "new_name", newName)
+		return &struct{ Body APIInstance }{Body: toAPIInstance(*current)}, nil
 	})
 
 	huma.Register(api, huma.Operation{
@@ -206,6 +207,8 @@ func registerInstances(api huma.API, deps *Dependencies) {
 		Description: "Delete an instance configuration.",
 		Tags:        []string{"Instances"},
 		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Extensions:  requiredRoleExtensions(services.RoleAdmin),
+		Middlewares: deps.RequireRoles(api, services.RoleAdmin),
 		Responses: map[string]*huma.Response{
 			"200": {Description: "Instance deleted successfully"},
 			"404": {Description: "Instance not found"},
@@ -215,15 +218,12 @@ func registerInstances(api huma.API, deps *Dependencies) {
 		AuthHeaders
 		Name string `path:"name" doc:"Instance name"`
 	}) (*struct{}, error) {
-		if err := deps.ensureAuth(input.Authorization); err != nil {
-			return nil, err
-		}
-		_, err := deps.Store.Update(func(spec *models.Spec) error {
-			idx, _ := findVersion(spec, input.Name)
+		_, err := deps.Store.Update(func(spec *models.BuilderSpec) error {
+			idx, _ := findInstance(spec, input.Name)
 			if idx == -1 {
 				return errInstanceNotFound
 			}
-			spec.Versions = append(spec.Versions[:idx], spec.Versions[idx+1:]...)
+			spec.Instances = append(spec.Instances[:idx], spec.Instances[idx+1:]...)
 			return nil
 		})
 		if err != nil {
@@ -238,43 +238,80 @@ func registerInstances(api huma.API, deps *Dependencies) {
 		Method:      http.MethodPost,
 		Path:        "/instances/build",
 		Summary:     "Build Instances",
-		Description: "Trigger a build process for all instances.",
+		Description: "Enqueue a build job for every instance, fanned out as one per-instance job per instance under a parent job. Returns the parent job.",
 		Tags:        []string{"Instances"},
 		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Extensions:  requiredRoleExtensions(services.RoleBuilder),
+		Middlewares: deps.RequireRoles(api, services.RoleBuilder),
 		Responses: map[string]*huma.Response{
-			"200": {Description: "Build started successfully"},
+			"200": {Description: "Build enqueued successfully"},
 			"400": {Description: "No instances to build"},
-			"409": {Description: "Build already running"},
 			"500": {Description: "Internal server error"},
 		},
 	}, func(ctx context.Context, input *struct {
 		AuthHeaders
 	}) (*struct {
-		Body struct {
-			Status string `json:"status"`
-		}
+		Body JobResponse
 	}, error) {
-		if err := deps.ensureAuth(input.Authorization); err != nil {
-			return nil, err
-		}
 		spec, err := deps.Store.GetSpec()
 		if err != nil {
 			return nil, huma.Error500InternalServerError(err.Error())
 		}
-		if len(spec.Versions) == 0 {
+		if len(spec.Instances) == 0 {
 			return nil, huma.Error400BadRequest("at least one instance required")
 		}
-		if err := deps.Runner.RunBuild(ctx); err != nil {
-			return nil, huma.Error409Conflict(err.Error())
+		if _, err := deps.Snapshots.Create(ctx, true, false); err != nil {
+			deps.Logger.Warn("automatic pre-build snapshot failed", "error", err)
 		}
-		deps.Logger.Info("build triggered")
-		return &struct {
-			Body struct {
-				Status string `json:"status"`
-			}
-		}{Body: struct {
-			Status string `json:"status"`
-		}{Status: "scheduled"}}, nil
+		names := make([]string, len(spec.Instances))
+		for i, instance := range spec.Instances {
+			names[i] = instance.Name
+		}
+		parent, _, err := deps.Jobs.EnqueueAll(names)
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		deps.Logger.Info("build enqueued", "job_id", parent.ID, "instances", len(names))
+		return &struct{ Body JobResponse }{Body: toJobResponse(parent)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "build-instance",
+		Method:      http.MethodPost,
+		Path:        "/instances/{name}/build",
+		Summary:     "Build Instance",
+		Description: "Enqueue a build job for a single instance, without rebuilding the others.",
+		Tags:        []string{"Instances"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Extensions:  requiredRoleExtensions(services.RoleBuilder),
+		Middlewares: deps.RequireRoles(api, services.RoleBuilder),
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Build enqueued successfully"},
+			"404": {Description: "Instance not found"},
+			"500": {Description: "Internal server error"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+		Name string `path:"name" doc:"Instance name"`
+	}) (*struct {
+		Body JobResponse
+	}, error) {
+		spec, err := deps.Store.GetSpec()
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		if _, instance := findInstance(spec, input.Name); instance == nil {
+			return nil, huma.Error404NotFound("instance not found")
+		}
+		if _, err := deps.Snapshots.Create(ctx, true, false); err != nil {
+			deps.Logger.Warn("automatic pre-build snapshot failed", "error", err)
+		}
+		job, err := deps.Jobs.Enqueue(input.Name)
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		deps.Logger.Info("build enqueued", "job_id", job.ID, "instance", input.Name)
+		return &struct{ Body JobResponse }{Body: toJobResponse(job)}, nil
 	})
 
 	huma.Register(api, huma.Operation{
@@ -301,21 +338,200 @@ func registerInstances(api huma.API, deps *Dependencies) {
 			Body BuildStatusResponse
 		}{Body: BuildStatusResponse{Status: deps.Runner.Status()}}, nil
 	})
+
+	registerInstanceUpload(api, deps)
+}
+
+// manifestKey returns the storage key an instance's upload manifest (a
+// relpath -> content-addressed-object index) lives under.
+func manifestKey(instanceName string) string {
+	return instanceKeyPrefix(instanceName) + "manifest.json"
 }
 
-func versionIndex(spec *models.Spec, name string) int {
-	for i := range spec.Versions {
-		if spec.Versions[i].Name == name {
+// ManifestEntryRequest is one file a client is about to upload, identified
+// by its relative path and content hash, as used by the preflight endpoint
+// below to figure out which files can be skipped.
+type ManifestEntryRequest struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+func registerInstanceUpload(api huma.API, deps *Dependencies) {
+	huma.Register(api, huma.Operation{
+		OperationID: "preflight-instance-files",
+		Method:      http.MethodPost,
+		Path:        "/instances/{name}/files/manifest",
+		Summary:     "Preflight Instance File Upload",
+		Description: "Given the path/sha256/size of files a client is about to upload, return the subset the server doesn't already have, so unchanged files can be skipped.",
+		Tags:        []string{"Instances"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Paths the server still needs"},
+			"404": {Description: "Instance not found"},
+			"500": {Description: "Internal server error"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+		Name string `path:"name" doc:"Instance name"`
+		Body []ManifestEntryRequest
+	}) (*struct {
+		Body struct {
+			Needed []string `json:"needed"`
+		}
+	}, error) {
+		if err := deps.ensureAuth(input.Authorization); err != nil {
+			return nil, err
+		}
+		spec, err := deps.Store.GetSpec()
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		if _, instance := findInstance(spec, input.Name); instance == nil {
+			return nil, huma.Error404NotFound("instance not found")
+		}
+
+		manifest, err := upload.LoadManifest(ctx, deps.Storage, manifestKey(input.Name))
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+
+		var resp struct {
+			Body struct {
+				Needed []string `json:"needed"`
+			}
+		}
+		resp.Body.Needed = []string{}
+		for _, entry := range input.Body {
+			rel, err := sanitizeRelativePath(entry.Path)
+			if err != nil {
+				return nil, huma.Error400BadRequest(err.Error())
+			}
+			if manifest.Needs(rel, entry.SHA256, entry.Size) {
+				resp.Body.Needed = append(resp.Body.Needed, entry.Path)
+			}
+		}
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:  "upload-instance-files",
+		Method:       http.MethodPost,
+		Path:         "/instances/{name}/files",
+		Summary:      "Upload Instance Files",
+		Description:  "Upload files to be included in an instance's build. Each file is streamed to a temp file while its SHA-256 is computed, then stored content-addressed so a later upload of the same bytes is skipped.",
+		Tags:         []string{"Instances"},
+		Security:     []map[string][]string{{"bearerAuth": {}}},
+		MaxBodyBytes: maxLauncherUploadBytes,
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Files uploaded successfully"},
+			"400": {Description: "No files uploaded"},
+			"404": {Description: "Instance not found"},
+			"500": {Description: "Internal server error"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+		Name  string                  `path:"name" doc:"Instance name"`
+		Files []*multipart.FileHeader `form:"files"`
+	}) (*struct{}, error) {
+		if err := deps.ensureAuth(input.Authorization); err != nil {
+			return nil, err
+		}
+		if len(input.Files) == 0 {
+			return nil, huma.Error400BadRequest("no files uploaded")
+		}
+
+		spec, err := deps.Store.GetSpec()
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		if _, instance := findInstance(spec, input.Name); instance == nil {
+			return nil, huma.Error404NotFound("instance not found")
+		}
+
+		manifest, err := upload.LoadManifest(ctx, deps.Storage, manifestKey(input.Name))
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+
+		for _, fh := range input.Files {
+			if err := putUploadedFile(ctx, deps.Storage, deps.Config.TempDir, input.Name, manifest, fh); err != nil {
+				return nil, huma.Error500InternalServerError(err.Error())
+			}
+		}
+
+		if err := manifest.Save(ctx, deps.Storage, manifestKey(input.Name)); err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+
+		deps.Logger.Info("instance files uploaded", "name", input.Name, "count", len(input.Files))
+		return &struct{}{}, nil
+	})
+}
+
+// putUploadedFile materializes one multipart file to a temp file while
+// hashing it, then stores it at its content-addressed key and records it in
+// manifest under its relative path. If an object with the same hash already
+// exists, the upload is a no-op other than the manifest entry: content
+// dedup falls out of the key scheme for free.
+func putUploadedFile(ctx context.Context, backend storage.Backend, tempDir, instanceName string, manifest *upload.Manifest, fh *multipart.FileHeader) error {
+	rel, err := sanitizeRelativePath(fh.Filename)
+	if err != nil {
+		return err
+	}
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	mat, err := upload.Materialize(tempDir, src)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(mat.Path)
+
+	const mode = 0o644
+	objectKey := instanceKeyPrefix(instanceName) + upload.ObjectKey(mat.SHA256)
+	if _, err := backend.Stat(ctx, objectKey); err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		f, err := os.Open(mat.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := backend.Put(ctx, objectKey, f, mat.Size, mode); err != nil {
+			return err
+		}
+	}
+
+	manifest.Files[rel] = upload.FileEntry{SHA256: mat.SHA256, Size: mat.Size, Mode: mode}
+	return nil
+}
+
+func sanitizeRelativePath(name string) (string, error) {
+	clean := path.Clean(strings.TrimLeft(filepath.ToSlash(name), "/"))
+	if clean == "" || clean == "." || strings.HasPrefix(clean, "../") || clean == ".." {
+		return "", fmt.Errorf("invalid file name: %s", name)
+	}
+	return clean, nil
+}
+
+func instanceIndex(spec *models.BuilderSpec, name string) int {
+	for i := range spec.Instances {
+		if spec.Instances[i].Name == name {
 			return i
 		}
 	}
 	return -1
 }
 
-func findVersion(spec *models.Spec, name string) (int, *models.VersionSpec) {
-	for i := range spec.Versions {
-		if spec.Versions[i].Name == name {
-			return i, &spec.Versions[i]
+func findInstance(spec *models.BuilderSpec, name string) (int, *models.BuilderInstance) {
+	for i := range spec.Instances {
+		if spec.Instances[i].Name == name {
+			return i, &spec.Instances[i]
 		}
 	}
 	return -1, nil
@@ -331,66 +547,11 @@ func mapAppError(err error) error {
 			return huma.Error404NotFound(appErr.Message)
 		case ErrCodeValidation:
 			return huma.Error422UnprocessableEntity(appErr.Message)
+		case ErrCodeXboxAccount:
+			return huma.Error403Forbidden(appErr.Message)
+		case ErrCodeForbidden:
+			return huma.Error403Forbidden(appErr.Message)
 		}
 	}
 	return huma.Error500InternalServerError(err.Error())
 }
-
-func ensureIncludeFrom(cfg *config.Config, version *models.VersionSpec) {
-	if strings.TrimSpace(version.IncludeFrom) == "" {
-		slug := slugifyName(version.Name)
-		version.IncludeFrom = filepath.ToSlash(filepath.Join(cfg.UploadedInstancesDir, slug))
-	}
-}
-
-func ensureAuthBackend(version *models.VersionSpec) {
-	if version.AuthBackend == nil {
-		version.AuthBackend = &models.AuthBackend{Type: models.AuthOffline}
-	}
-}
-
-func normalizeVersion(cfg *config.Config, version *models.VersionSpec) error {
-	version.Name = strings.TrimSpace(version.Name)
-	if version.Name == "" {
-		return NewValidationError("name", "name is required")
-	}
-	version.MinecraftVersion = strings.TrimSpace(version.MinecraftVersion)
-	if version.MinecraftVersion == "" {
-		return NewValidationError("minecraft_version", "minecraft_version is required")
-	}
-	if version.LoaderName == "" {
-		version.LoaderName = models.LoaderVanilla
-	}
-	if version.LoaderName != models.LoaderVanilla && strings.TrimSpace(version.LoaderVersion) == "" {
-		return NewValidationError("loader_version", "loader_version is required")
-	}
-
-	ensureIncludeFrom(cfg, version)
-	ensureAuthBackend(version)
-	return nil
-}
-
-func slugifyName(name string) string {
-	name = strings.TrimSpace(strings.ToLower(name))
-	if name == "" {
-		return "instance"
-	}
-	var builder strings.Builder
-	lastDash := false
-	for _, r := range name {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
-			builder.WriteRune(r)
-			lastDash = false
-			continue
-		}
-		if !lastDash {
-			builder.WriteRune('-')
-			lastDash = true
-		}
-	}
-	slug := strings.Trim(builder.String(), "-")
-	if slug == "" {
-		return "instance"
-	}
-	return slug
-}
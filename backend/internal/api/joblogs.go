@@ -0,0 +1,121 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// authenticateRequest accepts either a normal Authorization header or a
+// "token" query parameter, the latter for clients (EventSource, a plain
+// <a href> download link) that can't set custom headers.
+func (d *Dependencies) authenticateRequest(r *http.Request) bool {
+	if header := r.Header.Get("Authorization"); header != "" {
+		return d.ensureAuth(header) == nil
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return false
+	}
+	_, err := d.Auth.ValidateAdminToken(token)
+	return err == nil
+}
+
+func tailParam(r *http.Request) int {
+	tail, _ := strconv.Atoi(r.URL.Query().Get("tail"))
+	return tail
+}
+
+// handleJobLogsSSE streams a job's build log as Server-Sent Events: a replay
+// of up to `?tail=N` buffered lines, followed by live lines while the job is
+// still running. A finished job just gets the replay from its flushed log
+// file and the stream is closed immediately after.
+func (d *Dependencies) handleJobLogsSSE(w http.ResponseWriter, r *http.Request) {
+	if !d.authenticateRequest(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	d.streamJobLogs(w, r, chi.URLParam(r, "id"))
+}
+
+// handleLatestBuildLogsSSE streams the most recently triggered build's logs,
+// for operators who only know "a build is running" and not its job ID.
+func (d *Dependencies) handleLatestBuildLogsSSE(w http.ResponseWriter, r *http.Request) {
+	if !d.authenticateRequest(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	job, ok := d.Jobs.Latest()
+	if !ok {
+		http.Error(w, "no build has been triggered yet", http.StatusNotFound)
+		return
+	}
+	d.streamJobLogs(w, r, job.ID)
+}
+
+func (d *Dependencies) streamJobLogs(w http.ResponseWriter, r *http.Request, jobID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	replay, ch, unsubscribe, live := d.Jobs.Subscribe(jobID, tailParam(r))
+	if !live {
+		lines, err := d.Jobs.ReadLogFile(jobID)
+		if err != nil {
+			http.Error(w, "job logs not found", http.StatusNotFound)
+			return
+		}
+		replay = lines
+	} else {
+		defer unsubscribe()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, line := range replay {
+		fmt.Fprintf(w, "data: %s\n\n", line)
+	}
+	flusher.Flush()
+
+	if !live {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", line)
+			flusher.Flush()
+		}
+	}
+}
+
+// handleJobLogsDownload serves a finished job's flushed log file so
+// operators can grab it without tailing the stream live.
+func (d *Dependencies) handleJobLogsDownload(w http.ResponseWriter, r *http.Request) {
+	if !d.authenticateRequest(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	path := d.Jobs.LogFilePath(id)
+	if _, err := os.Stat(path); err != nil {
+		http.Error(w, "job log not available yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".log"))
+	http.ServeFile(w, r, path)
+}
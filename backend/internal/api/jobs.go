@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/jobs"
+)
+
+func registerJobs(api huma.API, deps *Dependencies) {
+	huma.Register(api, huma.Operation{
+		OperationID: "list-jobs",
+		Method:      http.MethodGet,
+		Path:        "/jobs",
+		Summary:     "List Jobs",
+		Description: "List build jobs, newest first, optionally filtered by instance and/or status.",
+		Tags:        []string{"Jobs"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Matching jobs"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+		Instance string      `query:"instance" doc:"Filter by instance name"`
+		Status   jobs.Status `query:"status" doc:"Filter by job status"`
+	}) (*struct {
+		Body []JobResponse
+	}, error) {
+		if err := deps.ensureAuth(input.Authorization); err != nil {
+			return nil, err
+		}
+		list := deps.Jobs.List(input.Instance, input.Status)
+		out := make([]JobResponse, len(list))
+		for i, j := range list {
+			out[i] = toJobResponse(j)
+		}
+		return &struct{ Body []JobResponse }{Body: out}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-job",
+		Method:      http.MethodGet,
+		Path:        "/jobs/{id}",
+		Summary:     "Get Job",
+		Description: "Get a single job's status, exit code and duration.",
+		Tags:        []string{"Jobs"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Job details"},
+			"404": {Description: "Job not found"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+		ID string `path:"id" doc:"Job ID"`
+	}) (*struct {
+		Body JobResponse
+	}, error) {
+		if err := deps.ensureAuth(input.Authorization); err != nil {
+			return nil, err
+		}
+		job, ok := deps.Jobs.Get(input.ID)
+		if !ok {
+			return nil, huma.Error404NotFound("job not found")
+		}
+		return &struct{ Body JobResponse }{Body: toJobResponse(job)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "cancel-job",
+		Method:      http.MethodPost,
+		Path:        "/jobs/{id}/cancel",
+		Summary:     "Cancel Job",
+		Description: "Cancel a queued job, or kill the builder process of a running one.",
+		Tags:        []string{"Jobs"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Job canceled"},
+			"404": {Description: "Job not found"},
+			"409": {Description: "Job already finished"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+		ID string `path:"id" doc:"Job ID"`
+	}) (*struct{}, error) {
+		if err := deps.ensureAuth(input.Authorization); err != nil {
+			return nil, err
+		}
+		if err := deps.Jobs.Cancel(input.ID); err != nil {
+			if err.Error() == "job not found" {
+				return nil, huma.Error404NotFound(err.Error())
+			}
+			return nil, huma.Error409Conflict(err.Error())
+		}
+		deps.Logger.Info("job canceled", "id", input.ID)
+		return &struct{}{}, nil
+	})
+}
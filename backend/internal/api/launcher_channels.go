@@ -0,0 +1,187 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/storage"
+)
+
+const defaultLauncherChannel = "stable"
+
+// LauncherVersion records one uploaded build of a launcher artifact within
+// a release channel.
+type LauncherVersion struct {
+	Version    string    `json:"version"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	SHA256     string    `json:"sha256"`
+	Size       int64     `json:"size"`
+	Pinned     bool      `json:"pinned,omitempty"`
+}
+
+// launcherChannelIndex is the full upload history for one OS/artifact/
+// channel triple, persisted as "versions.json" under its channel prefix.
+type launcherChannelIndex struct {
+	Versions []LauncherVersion `json:"versions"`
+}
+
+// launcherChannelPrefix returns the storage key prefix a channel's
+// versions, "versions.json" and "latest.json" live under.
+func launcherChannelPrefix(osName, artifact, channel string) string {
+	return fmt.Sprintf("launchers/%s/%s/%s/", osName, artifact, channel)
+}
+
+func launcherVersionKey(osName, artifact, channel, version, filename string) string {
+	return launcherChannelPrefix(osName, artifact, channel) + version + "/" + filename
+}
+
+func loadLauncherChannelIndex(ctx context.Context, backend storage.Backend, prefix string) (*launcherChannelIndex, error) {
+	rc, _, err := backend.Get(ctx, prefix+"versions.json")
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &launcherChannelIndex{}, nil
+		}
+		return nil, err
+	}
+	defer rc.Close()
+
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	var idx launcherChannelIndex
+	if err := json.Unmarshal(raw, &idx); err != nil {
+		return nil, err
+	}
+	return &idx, nil
+}
+
+func (idx *launcherChannelIndex) save(ctx context.Context, backend storage.Backend, prefix string) error {
+	raw, err := json.MarshalIndent(idx, "", "    ")
+	if err != nil {
+		return err
+	}
+	return backend.Put(ctx, prefix+"versions.json", bytes.NewReader(raw), int64(len(raw)), 0o644)
+}
+
+func loadLauncherLatest(ctx context.Context, backend storage.Backend, prefix string) (*LauncherVersion, error) {
+	rc, _, err := backend.Get(ctx, prefix+"latest.json")
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	var v LauncherVersion
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// resolveLauncherVersion looks up the metadata for version within a channel,
+// or the channel's latest release when version is empty.
+func resolveLauncherVersion(ctx context.Context, backend storage.Backend, prefix, version string) (*LauncherVersion, error) {
+	if version == "" {
+		return loadLauncherLatest(ctx, backend, prefix)
+	}
+
+	idx, err := loadLauncherChannelIndex(ctx, backend, prefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range idx.Versions {
+		if v.Version == version {
+			return &v, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func saveLauncherLatest(ctx context.Context, backend storage.Backend, prefix string, v LauncherVersion) error {
+	raw, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	return backend.Put(ctx, prefix+"latest.json", bytes.NewReader(raw), int64(len(raw)), 0o644)
+}
+
+// recordLauncherUpload adds/replaces v's entry in its channel's index,
+// refreshes latest.json, and enforces the retention policy, deleting the
+// artifact of any version retention drops.
+func recordLauncherUpload(ctx context.Context, deps *Dependencies, osName, artifact, channel, filename string, v LauncherVersion) error {
+	prefix := launcherChannelPrefix(osName, artifact, channel)
+	idx, err := loadLauncherChannelIndex(ctx, deps.Storage, prefix)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range idx.Versions {
+		if idx.Versions[i].Version == v.Version {
+			idx.Versions[i] = v
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		idx.Versions = append(idx.Versions, v)
+	}
+
+	removed := enforceLauncherRetention(idx, deps.Config.LauncherRetentionKeepLastN, deps.Config.LauncherKeepAllPinned)
+	for _, r := range removed {
+		key := launcherVersionKey(osName, artifact, channel, r.Version, filename)
+		if err := deps.Storage.Delete(ctx, key); err != nil {
+			deps.Logger.Warn("failed to delete retired launcher version", "key", key, "error", err)
+		}
+	}
+
+	if err := idx.save(ctx, deps.Storage, prefix); err != nil {
+		return err
+	}
+	return saveLauncherLatest(ctx, deps.Storage, prefix, v)
+}
+
+// enforceLauncherRetention trims idx.Versions down to at most keepLastN
+// non-pinned versions (newest first by upload time), optionally exempting
+// pinned versions from the count entirely when keepAllPinned is set, and
+// returns the versions it dropped. keepLastN <= 0 means unlimited history.
+func enforceLauncherRetention(idx *launcherChannelIndex, keepLastN int, keepAllPinned bool) []LauncherVersion {
+	if keepLastN <= 0 {
+		return nil
+	}
+	sort.Slice(idx.Versions, func(i, j int) bool {
+		return idx.Versions[i].UploadedAt.Before(idx.Versions[j].UploadedAt)
+	})
+
+	var kept, removed []LauncherVersion
+	nonPinnedKept := 0
+	for i := len(idx.Versions) - 1; i >= 0; i-- {
+		v := idx.Versions[i]
+		if v.Pinned && keepAllPinned {
+			kept = append(kept, v)
+			continue
+		}
+		if nonPinnedKept < keepLastN {
+			kept = append(kept, v)
+			nonPinnedKept++
+			continue
+		}
+		removed = append(removed, v)
+	}
+
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].UploadedAt.Before(kept[j].UploadedAt)
+	})
+	idx.Versions = kept
+	return removed
+}
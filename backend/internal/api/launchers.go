@@ -1,15 +1,23 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
-	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/storage"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/upload"
 )
 
 func getLauncherFilename(osName, artifact, launcherName string) (string, error) {
@@ -58,142 +66,256 @@ func launcherFileMode(osName, artifact string) os.FileMode {
 
 const maxLauncherUploadBytes int64 = 300 * 1024 * 1024
 
-type ArtifactResponse struct {
-	ContentDisposition string `header:"Content-Disposition"`
-	ContentType        string `header:"Content-Type"`
-	Body               []byte `content:"application/octet-stream"`
-}
-
-type VersionResponse struct {
-	ContentType string `header:"Content-Type"`
-	Body        []byte `content:"text/plain"`
+// launcherDownloadURL returns a URL the client can be redirected to fetch
+// key directly, bypassing the backend process, and whether one is
+// available. LauncherPublicBaseURL wins when set (a CDN/reverse proxy in
+// front of the storage backend); otherwise it falls back to a presigned URL
+// from the storage backend, if that backend supports one.
+func launcherDownloadURL(ctx context.Context, deps *Dependencies, key string) (string, bool, error) {
+	if deps.Config.LauncherPublicBaseURL != nil {
+		return strings.TrimRight(*deps.Config.LauncherPublicBaseURL, "/") + "/" + key, true, nil
+	}
+	url, err := deps.Storage.PresignedURL(ctx, key, 15*time.Minute)
+	if err != nil {
+		if errors.Is(err, storage.ErrPresignNotSupported) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return url, true, nil
 }
 
 func registerLaunchers(api huma.API, deps *Dependencies) {
 	huma.Register(api, huma.Operation{
-		OperationID: "get-launcher-artifact",
+		OperationID: "list-launcher-versions",
 		Method:      http.MethodGet,
-		Path:        "/launchers/{os}/{artifact}",
-		Summary:     "Download launcher artifact",
-		Description: "Download launcher artifact for the given OS and artifact type.",
+		Path:        "/launchers/{os}/{artifact}/versions",
+		Summary:     "List launcher artifact versions",
+		Description: "List the upload history for a channel (default stable), newest first, for rollback or release-note tooling.",
 		Tags:        []string{"Launchers"},
 	}, func(ctx context.Context, input *struct {
 		OS       string `path:"os" enum:"windows,macos,linux" doc:"Operating system"`
 		Artifact string `path:"artifact" enum:"exe,dmg,archive,bin,flatpak,flatpakref" doc:"Artifact type"`
-	}) (*ArtifactResponse, error) {
-		filename, err := getLauncherFilename(input.OS, input.Artifact, deps.Config.LauncherName)
-		if err != nil {
+		Channel  string `query:"channel" doc:"Release channel to list, e.g. stable, beta, nightly"`
+	}) (*struct {
+		Body []LauncherVersion
+	}, error) {
+		if _, err := getLauncherFilename(input.OS, input.Artifact, deps.Config.LauncherName); err != nil {
 			return nil, huma.Error400BadRequest(err.Error())
 		}
-		dir := filepath.Join(deps.Config.LauncherDir, input.OS, input.Artifact)
-		path := filepath.Join(dir, filename)
+		channel := input.Channel
+		if channel == "" {
+			channel = defaultLauncherChannel
+		}
 
-		raw, err := os.ReadFile(path)
+		idx, err := loadLauncherChannelIndex(ctx, deps.Storage, launcherChannelPrefix(input.OS, input.Artifact, channel))
 		if err != nil {
-			if os.IsNotExist(err) {
-				return nil, huma.Error404NotFound("artifact not uploaded")
-			}
-			return nil, huma.Error500InternalServerError("failed to read artifact")
+			return nil, huma.Error500InternalServerError(err.Error())
 		}
 
-		return &ArtifactResponse{
-			ContentDisposition: fmt.Sprintf("attachment; filename=%q", filename),
-			ContentType:        "application/octet-stream",
-			Body:               raw,
-		}, nil
+		versions := make([]LauncherVersion, len(idx.Versions))
+		for i, v := range idx.Versions {
+			versions[len(idx.Versions)-1-i] = v
+		}
+		return &struct{ Body []LauncherVersion }{Body: versions}, nil
 	})
+}
 
-	huma.Register(api, huma.Operation{
-		OperationID: "get-launcher-version",
-		Method:      http.MethodGet,
-		Path:        "/launchers/{os}/{artifact}/version",
-		Summary:     "Get launcher artifact version",
-		Description: "Return the version string for the latest uploaded launcher artifact.",
-		Tags:        []string{"Launchers"},
-	}, func(ctx context.Context, input *struct {
-		OS       string `path:"os" enum:"windows,macos,linux" doc:"Operating system"`
-		Artifact string `path:"artifact" enum:"exe,dmg,archive,bin,flatpak,flatpakref" doc:"Artifact type"`
-	}) (*VersionResponse, error) {
-		if _, err := getLauncherFilename(input.OS, input.Artifact, deps.Config.LauncherName); err != nil {
-			return nil, huma.Error400BadRequest(err.Error())
-		}
+var launcherArtifactPattern = regexp.MustCompile(`^(windows|macos|linux)/(exe|dmg|archive|bin|flatpak|flatpakref)$`)
 
-		dir := filepath.Join(deps.Config.LauncherDir, input.OS, input.Artifact)
-		versionPath := filepath.Join(dir, "version.txt")
+// handleUploadLauncher implements POST /launchers/{os}/{artifact} as a raw
+// handler instead of a huma operation, so the upload is streamed to a temp
+// file (tee-ing into a SHA-256 hash) and then pushed through the storage
+// backend, rather than huma binding the whole body into a []byte first.
+func (d *Dependencies) handleUploadLauncher(w http.ResponseWriter, r *http.Request) {
+	if !d.authenticateRequest(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-		raw, err := os.ReadFile(versionPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return nil, huma.Error404NotFound("artifact not uploaded")
-			}
-			return nil, huma.Error500InternalServerError("failed to read version")
-		}
+	osName, artifact := chi.URLParam(r, "os"), chi.URLParam(r, "artifact")
+	if !launcherArtifactPattern.MatchString(osName + "/" + artifact) {
+		http.Error(w, "invalid os/artifact", http.StatusBadRequest)
+		return
+	}
 
-		return &VersionResponse{
-			ContentType: "text/plain; charset=utf-8",
-			Body:        raw,
-		}, nil
-	})
+	version := strings.TrimSpace(r.URL.Query().Get("version"))
+	if version == "" {
+		http.Error(w, "version is required", http.StatusBadRequest)
+		return
+	}
+	channel := r.URL.Query().Get("channel")
+	if channel == "" {
+		channel = defaultLauncherChannel
+	}
+	pinned, _ := strconv.ParseBool(r.URL.Query().Get("pin"))
 
-	huma.Register(api, huma.Operation{
-		OperationID:  "upload-launcher",
-		Method:       http.MethodPost,
-		Path:         "/launchers/{os}/{artifact}",
-		Summary:      "Upload launcher artifact",
-		Description:  "Upload launcher artifact for an OS/artifact pair.",
-		Tags:         []string{"Launchers"},
-		MaxBodyBytes: maxLauncherUploadBytes,
-		Security: []map[string][]string{
-			{"bearerAuth": {}},
-		},
-	}, func(ctx context.Context, input *struct {
-		AuthHeaders
-		OS       string `path:"os" enum:"windows,macos,linux" doc:"Operating system"`
-		Artifact string `path:"artifact" enum:"exe,dmg,archive,bin,flatpak,flatpakref" doc:"Artifact type"`
-		Version  string `query:"version" doc:"Launcher version identifier (e.g. git sha)"`
-		RawBody  []byte
-	}) (*struct{}, error) {
-		if err := deps.ensureAuth(input.Authorization); err != nil {
-			return nil, err
-		}
+	filename, err := getLauncherFilename(osName, artifact, d.Config.LauncherName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		version := strings.TrimSpace(input.Version)
-		if version == "" {
-			return nil, huma.Error400BadRequest("version is required")
-		}
-		if len(input.RawBody) == 0 {
-			return nil, huma.Error400BadRequest("empty upload")
+	r.Body = http.MaxBytesReader(w, r.Body, maxLauncherUploadBytes)
+	mat, err := upload.Materialize(d.Config.TempDir, r.Body)
+	if err != nil {
+		http.Error(w, "failed to read upload", http.StatusBadRequest)
+		return
+	}
+	defer os.Remove(mat.Path)
+	if mat.Size == 0 {
+		http.Error(w, "empty upload", http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(mat.Path)
+	if err != nil {
+		http.Error(w, "failed to read upload", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	mode := launcherFileMode(osName, artifact)
+	key := launcherVersionKey(osName, artifact, channel, version, filename)
+	if err := d.Storage.Put(r.Context(), key, f, mat.Size, mode); err != nil {
+		d.Logger.Error("failed to write launcher file", "key", key, "error", err)
+		http.Error(w, "failed to write file", http.StatusInternalServerError)
+		return
+	}
+
+	release := LauncherVersion{
+		Version:    version,
+		UploadedAt: time.Now().UTC(),
+		SHA256:     mat.SHA256,
+		Size:       mat.Size,
+		Pinned:     pinned,
+	}
+	if err := recordLauncherUpload(r.Context(), d, osName, artifact, channel, filename, release); err != nil {
+		d.Logger.Error("failed to record launcher release", "os", osName, "artifact", artifact, "channel", channel, "error", err)
+		http.Error(w, "failed to record release", http.StatusInternalServerError)
+		return
+	}
+
+	d.Logger.Info(
+		"launcher uploaded",
+		"os", osName,
+		"artifact", artifact,
+		"channel", channel,
+		"version", version,
+		"filename", filename,
+		"mode", mode,
+		"sha256", mat.SHA256,
+		"pinned", pinned,
+		"ts", time.Now().UTC().Format(time.RFC3339),
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDownloadLauncherArtifact implements GET /launchers/{os}/{artifact} as
+// a raw handler instead of a huma operation, so it can hand the shared
+// coalesced download off to http.ServeContent: that gives us conditional GET
+// (If-None-Match/If-Modified-Since -> 304) and Range (-> 206) support for
+// free, over the *os.File the download coalescer returns, so launcher
+// self-updaters can resume interrupted downloads and skip re-fetching a
+// version they already have.
+func (d *Dependencies) handleDownloadLauncherArtifact(w http.ResponseWriter, r *http.Request) {
+	osName, artifact := chi.URLParam(r, "os"), chi.URLParam(r, "artifact")
+	if !launcherArtifactPattern.MatchString(osName + "/" + artifact) {
+		http.Error(w, "invalid os/artifact", http.StatusBadRequest)
+		return
+	}
+	filename, err := getLauncherFilename(osName, artifact, d.Config.LauncherName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	channel := r.URL.Query().Get("channel")
+	if channel == "" {
+		channel = defaultLauncherChannel
+	}
+	prefix := launcherChannelPrefix(osName, artifact, channel)
+
+	release, err := resolveLauncherVersion(r.Context(), d.Storage, prefix, r.URL.Query().Get("version"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "artifact not uploaded", http.StatusNotFound)
+			return
 		}
+		http.Error(w, "failed to read channel metadata", http.StatusInternalServerError)
+		return
+	}
+	key := launcherVersionKey(osName, artifact, channel, release.Version, filename)
 
-		filename, err := getLauncherFilename(input.OS, input.Artifact, deps.Config.LauncherName)
+	wantRedirect := d.Config.RedirectDownloads
+	if raw := r.URL.Query().Get("redirect"); raw != "" {
+		if parsed, err := strconv.ParseBool(raw); err == nil {
+			wantRedirect = parsed
+		}
+	}
+	if wantRedirect {
+		url, ok, err := launcherDownloadURL(r.Context(), d, key)
 		if err != nil {
-			return nil, huma.Error400BadRequest(err.Error())
+			http.Error(w, "failed to create download URL", http.StatusInternalServerError)
+			return
+		}
+		if ok {
+			http.Redirect(w, r, url, http.StatusFound)
+			return
 		}
+	}
 
-		dir := filepath.Join(deps.Config.LauncherDir, input.OS, input.Artifact)
-		if err := os.MkdirAll(dir, 0o755); err != nil {
-			deps.Logger.Error("failed to create launcher dir", "dir", dir, "error", err)
-			return nil, huma.Error500InternalServerError("failed to create directory")
+	result, joined, err := d.Downloads.Fetch(r.Context(), key, func(ctx context.Context) (io.ReadCloser, error) {
+		rc, _, err := d.Storage.Get(ctx, key)
+		return rc, err
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "artifact not uploaded", http.StatusNotFound)
+			return
 		}
+		http.Error(w, "failed to read artifact", http.StatusInternalServerError)
+		return
+	}
+	defer result.Close()
+	if joined > 1 {
+		d.Logger.Info("coalesced concurrent launcher downloads", "key", key, "joined", joined)
+	}
 
-		path := filepath.Join(dir, filename)
-		mode := launcherFileMode(input.OS, input.Artifact)
-		if err := os.WriteFile(path, input.RawBody, mode); err != nil {
-			deps.Logger.Error("failed to write launcher file", "path", path, "error", err)
-			return nil, huma.Error500InternalServerError("failed to write file")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", fmt.Sprintf("%q", release.SHA256))
+	http.ServeContent(w, r, filename, release.UploadedAt, result.File())
+}
+
+// handleGetLauncherVersion implements GET /launchers/{os}/{artifact}/version
+// as a raw handler so it can honor the same conditional-GET headers as the
+// artifact download, without clients needing to fetch the full binary just
+// to check whether they're already up to date.
+func (d *Dependencies) handleGetLauncherVersion(w http.ResponseWriter, r *http.Request) {
+	osName, artifact := chi.URLParam(r, "os"), chi.URLParam(r, "artifact")
+	if !launcherArtifactPattern.MatchString(osName + "/" + artifact) {
+		http.Error(w, "invalid os/artifact", http.StatusBadRequest)
+		return
+	}
+
+	channel := r.URL.Query().Get("channel")
+	if channel == "" {
+		channel = defaultLauncherChannel
+	}
+
+	latest, err := loadLauncherLatest(r.Context(), d.Storage, launcherChannelPrefix(osName, artifact, channel))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "artifact not uploaded", http.StatusNotFound)
+			return
 		}
+		http.Error(w, "failed to read version", http.StatusInternalServerError)
+		return
+	}
 
-		_ = os.WriteFile(filepath.Join(dir, "version.txt"), []byte(version+"\n"), 0o644)
-
-		deps.Logger.Info(
-			"launcher uploaded",
-			"os", input.OS,
-			"artifact", input.Artifact,
-			"version", version,
-			"filename", filename,
-			"mode", mode,
-			"ts", time.Now().UTC().Format(time.RFC3339),
-		)
-		return nil, nil
-	})
+	body := []byte(latest.Version + "\n")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("ETag", fmt.Sprintf("%q", latest.SHA256))
+	http.ServeContent(w, r, "version.txt", latest.UploadedAt, bytes.NewReader(body))
 }
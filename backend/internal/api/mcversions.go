@@ -10,6 +10,17 @@ import (
 	"github.com/Petr1Furious/potato-launcher/backend/internal/services"
 )
 
+// staleCacheHeader returns "stale" for the X-Cache response header when a
+// result was served from the stale-while-revalidate cache because its
+// upstream is currently failing or its circuit breaker is open; empty
+// string otherwise (huma omits headers set to their zero value).
+func staleCacheHeader(stale bool) string {
+	if stale {
+		return "stale"
+	}
+	return ""
+}
+
 func registerMCVersions(api huma.API, deps *Dependencies) {
 	huma.Register(api, huma.Operation{
 		OperationID: "list-mc-versions",
@@ -19,19 +30,22 @@ func registerMCVersions(api huma.API, deps *Dependencies) {
 		Description: "Get a list of available Minecraft versions.",
 		Tags:        []string{"MC Versions"},
 		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Extensions:  requiredRoleExtensions(services.RoleViewer),
+		Middlewares: deps.RequireRoles(api, services.RoleViewer),
 	}, func(ctx context.Context, input *struct {
 		AuthHeaders
 	}) (*struct {
-		Body []string
+		XCache string `header:"X-Cache"`
+		Body   []string
 	}, error) {
-		if err := deps.ensureAuth(input.Authorization); err != nil {
-			return nil, err
-		}
-		versions, err := services.GetVanillaVersions(ctx, "")
+		versions, stale, err := services.GetVanillaVersions(ctx, "")
 		if err != nil {
 			return nil, huma.Error503ServiceUnavailable(err.Error())
 		}
-		return &struct{ Body []string }{Body: versions}, nil
+		return &struct {
+			XCache string `header:"X-Cache"`
+			Body   []string
+		}{XCache: staleCacheHeader(stale), Body: versions}, nil
 	})
 
 	huma.Register(api, huma.Operation{
@@ -42,20 +56,23 @@ func registerMCVersions(api huma.API, deps *Dependencies) {
 		Description: "Get available loaders for a specific Minecraft version.",
 		Tags:        []string{"MC Versions"},
 		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Extensions:  requiredRoleExtensions(services.RoleViewer),
+		Middlewares: deps.RequireRoles(api, services.RoleViewer),
 	}, func(ctx context.Context, input *struct {
 		AuthHeaders
 		Version string `path:"version" doc:"Minecraft version"`
 	}) (*struct {
-		Body []models.LoaderType
+		XCache string `header:"X-Cache"`
+		Body   []models.LoaderType
 	}, error) {
-		if err := deps.ensureAuth(input.Authorization); err != nil {
-			return nil, err
-		}
-		loaders, err := services.GetLoadersForVersion(ctx, input.Version)
+		loaders, stale, err := services.GetLoadersForVersion(ctx, input.Version)
 		if err != nil {
 			return nil, huma.Error503ServiceUnavailable(err.Error())
 		}
-		return &struct{ Body []models.LoaderType }{Body: loaders}, nil
+		return &struct {
+			XCache string `header:"X-Cache"`
+			Body   []models.LoaderType
+		}{XCache: staleCacheHeader(stale), Body: loaders}, nil
 	})
 
 	huma.Register(api, huma.Operation{
@@ -66,20 +83,23 @@ func registerMCVersions(api huma.API, deps *Dependencies) {
 		Description: "Get specific versions for a loader on a Minecraft version.",
 		Tags:        []string{"MC Versions"},
 		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Extensions:  requiredRoleExtensions(services.RoleViewer),
+		Middlewares: deps.RequireRoles(api, services.RoleViewer),
 	}, func(ctx context.Context, input *struct {
 		AuthHeaders
 		Version string            `path:"version" doc:"Minecraft version"`
 		Loader  models.LoaderType `path:"loader" doc:"Loader type (e.g. forge, fabric)"`
 	}) (*struct {
-		Body []string
+		XCache string `header:"X-Cache"`
+		Body   []string
 	}, error) {
-		if err := deps.ensureAuth(input.Authorization); err != nil {
-			return nil, err
-		}
-		versions, err := services.GetLoaderVersions(ctx, input.Version, input.Loader)
+		versions, stale, err := services.GetLoaderVersions(ctx, input.Version, input.Loader)
 		if err != nil {
 			return nil, huma.Error503ServiceUnavailable(err.Error())
 		}
-		return &struct{ Body []string }{Body: versions}, nil
+		return &struct {
+			XCache string `header:"X-Cache"`
+			Body   []string
+		}{XCache: staleCacheHeader(stale), Body: versions}, nil
 	})
 }
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services"
+)
+
+// microsoftBackendInstance looks up id's instance and confirms it's
+// configured for the "microsoft" auth backend, the same way
+// registerOIDCAuthBackends checks an instance's oidc backend. Both
+// device-code endpoints are unauthenticated and instance-scoped: they're
+// consumed by players signing into that instance's Microsoft/Xbox account,
+// not by the backend admin.
+func (d *Dependencies) microsoftBackendInstance(id string) (*models.BuilderInstance, error) {
+	spec, err := d.Store.GetSpec()
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	_, instance := findInstance(spec, id)
+	if instance == nil || instance.AuthBackend == nil || instance.AuthBackend.Type != models.AuthMicrosoft {
+		return nil, huma.Error404NotFound("instance not found or not configured for microsoft auth")
+	}
+	return instance, nil
+}
+
+type MicrosoftPollRequest struct {
+	DeviceCode string `json:"device_code" doc:"The device_code returned by /auth-backends/{id}/microsoft/device_code"`
+}
+
+type microsoftPollResult struct {
+	Pending           bool      `json:"pending"`
+	AccessToken       string    `json:"access_token,omitempty"`
+	ExpiresAt         time.Time `json:"expires_at,omitempty"`
+	MinecraftUUID     string    `json:"minecraft_uuid,omitempty"`
+	MinecraftUsername string    `json:"minecraft_username,omitempty"`
+}
+
+// MicrosoftPollResponse uses huma's Status-field convention to report 202
+// while sign-in is still pending and 200 once the Minecraft session is ready,
+// from one operation.
+type MicrosoftPollResponse struct {
+	Status int
+	Body   microsoftPollResult
+}
+
+// registerMicrosoftAuth exposes the Microsoft device-code flow scoped to one
+// instance's "microsoft" auth backend, the same way registerOIDCAuthBackends
+// exposes an instance's oidc descriptor: unauthenticated, so a player can
+// sign into that instance without ever holding an admin token.
+func registerMicrosoftAuth(api huma.API, deps *Dependencies) {
+	huma.Register(api, huma.Operation{
+		OperationID: "microsoft-device-code",
+		Method:      http.MethodPost,
+		Path:        "/auth-backends/{id}/microsoft/device_code",
+		Summary:     "Start Microsoft device-code login",
+		Description: "Start a Microsoft OAuth device-code flow (Microsoft -> Xbox Live -> XSTS -> Minecraft Services) for an instance configured with the microsoft auth backend. Display user_code and verification_uri to the player, then poll /auth-backends/{id}/microsoft/poll with device_code at the returned interval.",
+		Tags:        []string{"Authorization"},
+		Responses: map[string]*huma.Response{
+			"404": {Description: "Instance not found or not configured for microsoft auth"},
+			"503": {Description: "Microsoft auth is not configured, or upstream is unreachable"},
+		},
+	}, func(ctx context.Context, input *struct {
+		ID string `path:"id" doc:"Instance name"`
+	}) (*struct {
+		Body services.DeviceCodeResponse
+	}, error) {
+		if _, err := deps.microsoftBackendInstance(input.ID); err != nil {
+			return nil, err
+		}
+		code, err := deps.Microsoft.RequestDeviceCode(ctx)
+		if err != nil {
+			return nil, huma.Error503ServiceUnavailable(err.Error())
+		}
+		return &struct{ Body services.DeviceCodeResponse }{Body: *code}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "microsoft-poll",
+		Method:      http.MethodPost,
+		Path:        "/auth-backends/{id}/microsoft/poll",
+		Summary:     "Poll Microsoft device-code login",
+		Description: "Make one attempt to redeem a device_code for a Minecraft session. Returns 202 with pending=true until the player finishes signing in; the caller should retry at the interval /auth-backends/{id}/microsoft/device_code returned.",
+		Tags:        []string{"Authorization"},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Minecraft session acquired"},
+			"202": {Description: "Sign-in still pending"},
+			"403": {Description: "Xbox account error (no Xbox profile, child account, etc.)"},
+			"404": {Description: "Instance not found or not configured for microsoft auth"},
+		},
+	}, func(ctx context.Context, input *struct {
+		ID   string `path:"id" doc:"Instance name"`
+		Body MicrosoftPollRequest
+	}) (*MicrosoftPollResponse, error) {
+		if _, err := deps.microsoftBackendInstance(input.ID); err != nil {
+			return nil, err
+		}
+
+		session, err := deps.Microsoft.Poll(ctx, input.ID, input.Body.DeviceCode)
+		if err != nil {
+			if errors.Is(err, services.ErrAuthorizationPending) {
+				return &MicrosoftPollResponse{Status: http.StatusAccepted, Body: microsoftPollResult{Pending: true}}, nil
+			}
+			var xboxErr *services.XboxAccountError
+			if errors.As(err, &xboxErr) {
+				return nil, mapAppError(NewXboxAccountError(xboxErr.Message))
+			}
+			return nil, huma.Error503ServiceUnavailable(err.Error())
+		}
+
+		return &MicrosoftPollResponse{
+			Status: http.StatusOK,
+			Body: microsoftPollResult{
+				AccessToken:       session.AccessToken,
+				ExpiresAt:         session.ExpiresAt,
+				MinecraftUUID:     session.MinecraftUUID,
+				MinecraftUsername: session.MinecraftUsername,
+			},
+		}, nil
+	})
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
+)
+
+// OIDCConfigResponse is the descriptor a launcher client needs to start a
+// PKCE authorization-code flow against an instance's OIDC identity provider,
+// without hardcoding any of its endpoints.
+type OIDCConfigResponse struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	ClientID              string   `json:"client_id"`
+	Scopes                []string `json:"scopes"`
+	UsernameClaim         string   `json:"username_claim"`
+}
+
+// registerOIDCAuthBackends exposes the OIDC auth-backend descriptor for an
+// instance. It's unauthenticated: it's consumed by the launcher client
+// itself to start a PKCE flow, the same way the launcher download endpoints
+// are public.
+func registerOIDCAuthBackends(api huma.API, deps *Dependencies) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-oidc-auth-backend-config",
+		Method:      http.MethodGet,
+		Path:        "/auth-backends/{id}/oidc-config",
+		Summary:     "Get OIDC Auth Backend Config",
+		Description: "Get the OIDC descriptor (authorization/token endpoints, client_id, scopes, username claim) for an instance's oidc auth backend, so a launcher client can start a PKCE flow without hardcoding endpoints.",
+		Tags:        []string{"Authorization"},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "OIDC descriptor"},
+			"404": {Description: "Instance not found or not configured for oidc"},
+			"503": {Description: "Issuer discovery failed"},
+		},
+	}, func(ctx context.Context, input *struct {
+		ID string `path:"id" doc:"Instance name"`
+	}) (*struct {
+		Body OIDCConfigResponse
+	}, error) {
+		spec, err := deps.Store.GetSpec()
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		_, instance := findInstance(spec, input.ID)
+		if instance == nil || instance.AuthBackend == nil || instance.AuthBackend.Type != models.AuthOIDC {
+			return nil, huma.Error404NotFound("instance not found or not configured for oidc")
+		}
+
+		provider, err := deps.OIDC.Discover(ctx, instance.AuthBackend.IssuerURL)
+		if err != nil {
+			return nil, huma.Error503ServiceUnavailable(err.Error())
+		}
+
+		return &struct{ Body OIDCConfigResponse }{Body: OIDCConfigResponse{
+			Issuer:                instance.AuthBackend.IssuerURL,
+			AuthorizationEndpoint: provider.Endpoint().AuthURL,
+			TokenEndpoint:         provider.Endpoint().TokenURL,
+			ClientID:              instance.AuthBackend.ClientID,
+			Scopes:                instance.AuthBackend.Scopes,
+			UsernameClaim:         instance.AuthBackend.UsernameClaim,
+		}}, nil
+	})
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func registerSnapshots(api huma.API, deps *Dependencies) {
+	huma.Register(api, huma.Operation{
+		OperationID: "create-snapshot",
+		Method:      http.MethodPost,
+		Path:        "/snapshots",
+		Summary:     "Create Snapshot",
+		Description: "Back up the current spec.json (and optionally every instance's uploaded files) into a timestamped tar.gz.",
+		Tags:        []string{"Snapshots"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Snapshot created successfully"},
+			"500": {Description: "Internal server error"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+		Body CreateSnapshotRequest
+	}) (*struct {
+		Body SnapshotResponse
+	}, error) {
+		if err := deps.ensureAuth(input.Authorization); err != nil {
+			return nil, err
+		}
+		snap, err := deps.Snapshots.Create(ctx, false, input.Body.WithDirs)
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		deps.Logger.Info("snapshot created", "id", snap.ID, "with_dirs", snap.WithDirs)
+		return &struct{ Body SnapshotResponse }{Body: toSnapshotResponse(snap)}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-snapshots",
+		Method:      http.MethodGet,
+		Path:        "/snapshots",
+		Summary:     "List Snapshots",
+		Description: "List snapshots, newest first, with size/created-at/hash.",
+		Tags:        []string{"Snapshots"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "List of snapshots"},
+			"500": {Description: "Internal server error"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+	}) (*struct {
+		Body []SnapshotResponse
+	}, error) {
+		if err := deps.ensureAuth(input.Authorization); err != nil {
+			return nil, err
+		}
+		list, err := deps.Snapshots.List()
+		if err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		out := make([]SnapshotResponse, len(list))
+		for i, s := range list {
+			out[i] = toSnapshotResponse(s)
+		}
+		return &struct{ Body []SnapshotResponse }{Body: out}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "restore-snapshot",
+		Method:      http.MethodPost,
+		Path:        "/snapshots/{id}/restore",
+		Summary:     "Restore Snapshot",
+		Description: "Atomically swap the live spec.json back to a snapshot's, and optionally re-materialize instance files.",
+		Tags:        []string{"Snapshots"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Snapshot restored successfully"},
+			"404": {Description: "Snapshot not found"},
+			"500": {Description: "Internal server error"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+		ID   string `path:"id" doc:"Snapshot ID"`
+		Body RestoreSnapshotRequest
+	}) (*struct{}, error) {
+		if err := deps.ensureAuth(input.Authorization); err != nil {
+			return nil, err
+		}
+		if _, err := deps.Snapshots.Get(input.ID); err != nil {
+			return nil, huma.Error404NotFound(err.Error())
+		}
+		if err := deps.Snapshots.Restore(ctx, input.ID, input.Body.RestoreDirs); err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		deps.Logger.Info("snapshot restored", "id", input.ID, "restore_dirs", input.Body.RestoreDirs)
+		return &struct{}{}, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "delete-snapshot",
+		Method:      http.MethodDelete,
+		Path:        "/snapshots/{id}",
+		Summary:     "Delete Snapshot",
+		Description: "Prune a snapshot.",
+		Tags:        []string{"Snapshots"},
+		Security:    []map[string][]string{{"bearerAuth": {}}},
+		Responses: map[string]*huma.Response{
+			"200": {Description: "Snapshot deleted successfully"},
+			"404": {Description: "Snapshot not found"},
+			"500": {Description: "Internal server error"},
+		},
+	}, func(ctx context.Context, input *struct {
+		AuthHeaders
+		ID string `path:"id" doc:"Snapshot ID"`
+	}) (*struct{}, error) {
+		if err := deps.ensureAuth(input.Authorization); err != nil {
+			return nil, err
+		}
+		if _, err := deps.Snapshots.Get(input.ID); err != nil {
+			return nil, huma.Error404NotFound(err.Error())
+		}
+		if err := deps.Snapshots.Delete(input.ID); err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		deps.Logger.Info("snapshot deleted", "id", input.ID)
+		return &struct{}{}, nil
+	})
+}
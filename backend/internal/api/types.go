@@ -1,6 +1,11 @@
 package api
 
-import "github.com/Petr1Furious/potato-launcher/backend/internal/models"
+import (
+	"time"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/jobs"
+)
 
 type TokenRequest struct {
 	Token string `json:"token" doc:"Admin secret token"`
@@ -33,3 +38,34 @@ type APISpec struct {
 type BuildStatusResponse struct {
 	Status models.BuildStatus `json:"status"`
 }
+
+type SnapshotResponse struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Automatic bool      `json:"automatic"`
+	WithDirs  bool      `json:"with_dirs"`
+}
+
+type CreateSnapshotRequest struct {
+	WithDirs bool `json:"with_dirs" doc:"Also snapshot every instance's uploaded files, not just spec.json"`
+}
+
+type RestoreSnapshotRequest struct {
+	RestoreDirs bool `json:"restore_dirs" doc:"Also re-materialize every instance's uploaded files from the snapshot"`
+}
+
+type JobResponse struct {
+	ID         string      `json:"id"`
+	Kind       string      `json:"kind" example:"build_instance"`
+	Instance   string      `json:"instance,omitempty"`
+	ParentID   string      `json:"parent_id,omitempty"`
+	Status     jobs.Status `json:"status"`
+	ExitCode   *int        `json:"exit_code,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	DurationMs int64       `json:"duration_ms"`
+}
@@ -10,48 +10,94 @@ import (
 )
 
 type Config struct {
-	Host                     string
-	Port                     int
-	AdminSecretToken         string
-	AdminJWTSecret           string
-	AccessTokenExpireMinutes int
-	AllowedOrigins           []string
-	TempDir                  string
-	UploadedModpacksDir      string
-	SpecFile                 string
-	InstanceBuilderBinary    string
-	GeneratedDir             string
-	WorkdirDir               string
-	DownloadServerBase       string
-	ResourcesURLBase         *string
-	ReplaceDownloadURLs      bool
-	ExecBeforeAll            string
-	ExecAfterAll             string
+	Host                       string
+	Port                       int
+	AdminSecretToken           string
+	AdminJWTSecret             string
+	AccessTokenExpireMinutes   int
+	AllowedOrigins             []string
+	TempDir                    string
+	UploadedInstancesDir       string
+	SpecFile                   string
+	InstanceBuilderBinary      string
+	GeneratedDir               string
+	WorkdirDir                 string
+	LauncherName               string
+	DownloadServerBase         string
+	ResourcesURLBase           *string
+	ReplaceDownloadURLs        bool
+	ExecBeforeAll              string
+	ExecAfterAll               string
+	StorageBackend             string
+	StorageEndpoint            string
+	StorageBucket              string
+	StorageAccessKey           string
+	StorageSecretKey           string
+	StorageUseSSL              bool
+	JobsFile                   string
+	JobHistoryPerInstance      int
+	JobWorkerConcurrency       int
+	JobLogMaxBytes             int
+	SnapshotsDir               string
+	SnapshotRetention          int
+	AgentMode                  bool
+	AgentPollTimeoutSeconds    int
+	RedirectDownloads          bool
+	LauncherPublicBaseURL      *string
+	LauncherRetentionKeepLastN int
+	LauncherKeepAllPinned      bool
+	MicrosoftClientID          string
+	MicrosoftTokensFile        string
+	MCVersionsCacheTTLSeconds  int
 }
 
 func Load() (*Config, error) {
 	cfg := &Config{
-		Host:                     getEnv("HOST", "0.0.0.0"),
-		Port:                     getEnvInt("PORT", 8000),
-		AdminSecretToken:         os.Getenv("ADMIN_SECRET_TOKEN"),
-		AdminJWTSecret:           os.Getenv("ADMIN_JWT_SECRET"),
-		AccessTokenExpireMinutes: getEnvInt("ACCESS_TOKEN_EXPIRE_MINUTES", 1440),
-		AllowedOrigins:           splitAndClean(getEnv("ALLOWED_ORIGINS", "*")),
-		TempDir:                  getEnv("TEMP_DIR", os.TempDir()),
-		UploadedModpacksDir:      getEnv("UPLOADED_MODPACKS_DIR", "/data/modpacks"),
-		SpecFile:                 getEnv("SPEC_FILE", "/data/metadata/spec.json"),
-		InstanceBuilderBinary:    getEnv("INSTANCE_BUILDER_BINARY", "instance_builder"),
-		GeneratedDir:             getEnv("GENERATED_DIR", "/data/generated"),
-		WorkdirDir:               getEnv("WORKDIR_DIR", "/data/workdir"),
-		DownloadServerBase:       os.Getenv("DOWNLOAD_SERVER_BASE"),
-		ExecBeforeAll:            os.Getenv("EXEC_BEFORE_ALL"),
-		ExecAfterAll:             os.Getenv("EXEC_AFTER_ALL"),
-		ReplaceDownloadURLs:      getEnvBool("REPLACE_DOWNLOAD_URLS", false),
+		Host:                       getEnv("HOST", "0.0.0.0"),
+		Port:                       getEnvInt("PORT", 8000),
+		AdminSecretToken:           os.Getenv("ADMIN_SECRET_TOKEN"),
+		AdminJWTSecret:             os.Getenv("ADMIN_JWT_SECRET"),
+		AccessTokenExpireMinutes:   getEnvInt("ACCESS_TOKEN_EXPIRE_MINUTES", 1440),
+		AllowedOrigins:             splitAndClean(getEnv("ALLOWED_ORIGINS", "*")),
+		TempDir:                    getEnv("TEMP_DIR", os.TempDir()),
+		UploadedInstancesDir:       getEnv("UPLOADED_INSTANCES_DIR", "/data/instances"),
+		SpecFile:                   getEnv("SPEC_FILE", "/data/metadata/spec.json"),
+		InstanceBuilderBinary:      getEnv("INSTANCE_BUILDER_BINARY", "instance_builder"),
+		GeneratedDir:               getEnv("GENERATED_DIR", "/data/generated"),
+		WorkdirDir:                 getEnv("WORKDIR_DIR", "/data/workdir"),
+		LauncherName:               getEnv("LAUNCHER_NAME", "potato-launcher"),
+		DownloadServerBase:         os.Getenv("DOWNLOAD_SERVER_BASE"),
+		ExecBeforeAll:              os.Getenv("EXEC_BEFORE_ALL"),
+		ExecAfterAll:               os.Getenv("EXEC_AFTER_ALL"),
+		ReplaceDownloadURLs:        getEnvBool("REPLACE_DOWNLOAD_URLS", false),
+		StorageBackend:             getEnv("STORAGE_BACKEND", "local"),
+		StorageEndpoint:            os.Getenv("STORAGE_ENDPOINT"),
+		StorageBucket:              os.Getenv("STORAGE_BUCKET"),
+		StorageAccessKey:           os.Getenv("STORAGE_ACCESS_KEY"),
+		StorageSecretKey:           os.Getenv("STORAGE_SECRET_KEY"),
+		StorageUseSSL:              getEnvBool("STORAGE_USE_SSL", true),
+		JobsFile:                   getEnv("JOBS_FILE", "/data/metadata/jobs.json"),
+		JobHistoryPerInstance:      getEnvInt("JOB_HISTORY_PER_INSTANCE", 20),
+		JobWorkerConcurrency:       getEnvInt("JOB_WORKER_CONCURRENCY", 1),
+		JobLogMaxBytes:             getEnvInt("JOB_LOG_MAX_BYTES", 2<<20),
+		SnapshotsDir:               getEnv("SNAPSHOTS_DIR", "/data/snapshots"),
+		SnapshotRetention:          getEnvInt("SNAPSHOT_RETENTION", 10),
+		AgentMode:                  getEnvBool("AGENT_MODE", false),
+		AgentPollTimeoutSeconds:    getEnvInt("AGENT_POLL_TIMEOUT_SECONDS", 25),
+		RedirectDownloads:          getEnvBool("REDIRECT_DOWNLOADS", false),
+		LauncherRetentionKeepLastN: getEnvInt("LAUNCHER_RETENTION_KEEP_LAST_N", 10),
+		LauncherKeepAllPinned:      getEnvBool("LAUNCHER_KEEP_ALL_PINNED", true),
+		MicrosoftClientID:          os.Getenv("MICROSOFT_CLIENT_ID"),
+		MicrosoftTokensFile:        getEnv("MICROSOFT_TOKENS_FILE", "/data/metadata/microsoft_tokens.json"),
+		MCVersionsCacheTTLSeconds:  getEnvInt("MC_VERSIONS_CACHE_TTL_SECONDS", 300),
 	}
 
 	if resources := os.Getenv("RESOURCES_URL_BASE"); resources != "" {
 		cfg.ResourcesURLBase = &resources
 	}
+	if launcherBase := os.Getenv("LAUNCHER_PUBLIC_BASE_URL"); launcherBase != "" {
+		cfg.LauncherPublicBaseURL = &launcherBase
+	}
 
 	if cfg.AdminSecretToken == "" {
 		return nil, errors.New("ADMIN_SECRET_TOKEN is required")
@@ -62,6 +108,15 @@ func Load() (*Config, error) {
 	if cfg.DownloadServerBase == "" {
 		return nil, errors.New("DOWNLOAD_SERVER_BASE is required")
 	}
+	switch cfg.StorageBackend {
+	case "local":
+	case "s3":
+		if cfg.StorageEndpoint == "" || cfg.StorageBucket == "" || cfg.StorageAccessKey == "" || cfg.StorageSecretKey == "" {
+			return nil, errors.New("STORAGE_ENDPOINT, STORAGE_BUCKET, STORAGE_ACCESS_KEY and STORAGE_SECRET_KEY are required when STORAGE_BACKEND=s3")
+		}
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", cfg.StorageBackend)
+	}
 
 	if cfg.ResourcesURLBase == nil {
 		base := strings.TrimRight(cfg.DownloadServerBase, "/")
@@ -71,10 +126,13 @@ func Load() (*Config, error) {
 
 	for _, dir := range []string{
 		cfg.TempDir,
-		cfg.UploadedModpacksDir,
+		cfg.UploadedInstancesDir,
 		cfg.GeneratedDir,
 		cfg.WorkdirDir,
 		filepath.Dir(cfg.SpecFile),
+		filepath.Dir(cfg.JobsFile),
+		filepath.Dir(cfg.MicrosoftTokensFile),
+		cfg.SnapshotsDir,
 	} {
 		if err := ensureDir(dir); err != nil {
 			return nil, err
@@ -85,18 +143,27 @@ func Load() (*Config, error) {
 	if cfg.TempDir, err = filepath.Abs(cfg.TempDir); err != nil {
 		return nil, err
 	}
-	if cfg.UploadedModpacksDir, err = filepath.Abs(cfg.UploadedModpacksDir); err != nil {
+	if cfg.UploadedInstancesDir, err = filepath.Abs(cfg.UploadedInstancesDir); err != nil {
 		return nil, err
 	}
 	if cfg.SpecFile, err = filepath.Abs(cfg.SpecFile); err != nil {
 		return nil, err
 	}
+	if cfg.JobsFile, err = filepath.Abs(cfg.JobsFile); err != nil {
+		return nil, err
+	}
+	if cfg.MicrosoftTokensFile, err = filepath.Abs(cfg.MicrosoftTokensFile); err != nil {
+		return nil, err
+	}
 	if cfg.GeneratedDir, err = filepath.Abs(cfg.GeneratedDir); err != nil {
 		return nil, err
 	}
 	if cfg.WorkdirDir, err = filepath.Abs(cfg.WorkdirDir); err != nil {
 		return nil, err
 	}
+	if cfg.SnapshotsDir, err = filepath.Abs(cfg.SnapshotsDir); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
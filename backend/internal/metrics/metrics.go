@@ -0,0 +1,54 @@
+// Package metrics defines the Prometheus collectors exposed at /metrics and
+// the helpers other packages use to update them.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// BuildDuration records how long a build run took, from BuildRunning to
+	// BuildIdle, per instance it built.
+	BuildDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "potato_build_duration_seconds",
+		Help:    "Duration of a build run, from BuildRunning to BuildIdle.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"instance_name", "loader_name"})
+
+	// BuildStatus is 1 while a build is running for an instance, 0
+	// otherwise, so operators can alert on a build stuck at 1.
+	BuildStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "potato_build_status",
+		Help: "Whether a build is currently running for an instance (1) or not (0).",
+	}, []string{"instance_name"})
+
+	// UpstreamFetchTotal counts upstream Minecraft version API calls by
+	// outcome ("success", "failure", "breaker_open").
+	UpstreamFetchTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "potato_upstream_fetch_total",
+		Help: "Count of upstream Minecraft version API fetches by outcome.",
+	}, []string{"source", "outcome"})
+
+	// UpstreamFetchDuration records how long an attempted upstream fetch
+	// took (including its retries), excluding cache hits and calls skipped
+	// because the breaker was open.
+	UpstreamFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "potato_upstream_fetch_duration_seconds",
+		Help: "Duration of upstream Minecraft version API fetches.",
+	}, []string{"source"})
+
+	// AuthAttemptsTotal counts admin/agent token validations by result
+	// ("success", "failure").
+	AuthAttemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "potato_auth_attempts_total",
+		Help: "Count of authentication attempts by backend and result.",
+	}, []string{"backend", "result"})
+
+	// HTTPRequestsTotal counts HTTP requests handled by the Huma API,
+	// labeled by its OperationID (e.g. "list-mc-versions") and status code.
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Count of HTTP requests handled, by operation and status.",
+	}, []string{"operation", "status"})
+)
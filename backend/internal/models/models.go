@@ -9,15 +9,18 @@ const (
 	LoaderForge   LoaderType = "forge"
 	LoaderFabric  LoaderType = "fabric"
 	LoaderNeo     LoaderType = "neoforge"
+	LoaderQuilt   LoaderType = "quilt"
 )
 
 type AuthType string
 
 const (
-	AuthMojang   AuthType = "mojang"
-	AuthTelegram AuthType = "telegram"
-	AuthEly      AuthType = "ely.by"
-	AuthOffline  AuthType = "offline"
+	AuthMojang    AuthType = "mojang"
+	AuthMicrosoft AuthType = "microsoft"
+	AuthTelegram  AuthType = "telegram"
+	AuthEly       AuthType = "ely.by"
+	AuthOffline   AuthType = "offline"
+	AuthOIDC      AuthType = "oidc"
 )
 
 type AuthBackend struct {
@@ -25,6 +28,11 @@ type AuthBackend struct {
 	AuthBaseURL  string   `json:"auth_base_url,omitempty"`
 	ClientID     string   `json:"client_id,omitempty"`
 	ClientSecret string   `json:"client_secret,omitempty"`
+
+	// OIDC-only fields, used when Type == AuthOIDC.
+	IssuerURL     string   `json:"issuer_url,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+	UsernameClaim string   `json:"username_claim,omitempty"`
 }
 
 type IncludeRule struct {
@@ -2,13 +2,37 @@ package services
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 
 	"github.com/Petr1Furious/potato-launcher/backend/internal/config"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/metrics"
 )
 
+// agentSubjectPrefix marks a token as identifying a build agent rather than
+// the single shared admin session, e.g. "agent:worker-1".
+const agentSubjectPrefix = "agent:"
+
+// Roles an admin session token can carry. The single shared admin login
+// (see CreateAccessToken's "single_user" caller in internal/api/auth.go)
+// is granted every role; they exist so individual endpoints can require
+// less than full admin via ensureRole/RequireRoles.
+const (
+	RoleAdmin   = "admin"
+	RoleBuilder = "builder"
+	RoleViewer  = "viewer"
+)
+
+// JWTClaims extends the standard registered claims with the roles granted
+// to the session, so handlers can authorize at finer granularity than
+// "any valid admin session" via ensureRole/RequireRoles.
+type JWTClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+}
+
 type AuthService struct {
 	cfg *config.Config
 }
@@ -17,28 +41,91 @@ func NewAuthService(cfg *config.Config) *AuthService {
 	return &AuthService{cfg: cfg}
 }
 
-func (a *AuthService) CreateAccessToken(subject string) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Subject:   subject,
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(a.cfg.AccessTokenExpireMinutes) * time.Minute)),
+func (a *AuthService) CreateAccessToken(subject string, roles []string) (string, error) {
+	claims := JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(a.cfg.AccessTokenExpireMinutes) * time.Minute)),
+		},
+		Roles: roles,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(a.cfg.AdminJWTSecret))
 }
 
-func (a *AuthService) ValidateToken(raw string) (*jwt.RegisteredClaims, error) {
-	token, err := jwt.ParseWithClaims(raw, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+// CreateAgentToken mints a token identifying a single build agent, so it can
+// authenticate against the Agent.Poll/ReportProgress/UploadArtifact/Complete
+// endpoints (internal/agentrpc) without sharing the admin session token. Agent
+// tokens carry no roles: they're validated via ValidateAgentToken, not
+// ensureRole/RequireRoles.
+func (a *AuthService) CreateAgentToken(agentID string) (string, error) {
+	return a.CreateAccessToken(agentSubjectPrefix+agentID, nil)
+}
+
+// ValidateToken checks that raw is a JWT signed by us with a subject we
+// recognize: either the single shared admin session ("single_user") or a
+// build agent ("agent:<id>"). Callers that need to tell those apart should
+// use ValidateAdminToken or ValidateAgentToken instead.
+func (a *AuthService) ValidateToken(raw string) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(raw, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(a.cfg.AdminJWTSecret), nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*JWTClaims)
 	if !ok || !token.Valid {
 		return nil, errors.New("invalid JWT")
 	}
-	if claims.Subject != "single_user" {
+	if claims.Subject != "single_user" && !strings.HasPrefix(claims.Subject, agentSubjectPrefix) {
 		return nil, errors.New("invalid JWT subject")
 	}
 	return claims, nil
 }
+
+// ValidateAdminToken validates raw as the shared admin session token,
+// rejecting agent tokens even though both are signed with AdminJWTSecret.
+func (a *AuthService) ValidateAdminToken(raw string) (*JWTClaims, error) {
+	claims, err := a.validateAdminToken(raw)
+	if err != nil {
+		metrics.AuthAttemptsTotal.WithLabelValues("admin", "failure").Inc()
+		return nil, err
+	}
+	metrics.AuthAttemptsTotal.WithLabelValues("admin", "success").Inc()
+	return claims, nil
+}
+
+func (a *AuthService) validateAdminToken(raw string) (*JWTClaims, error) {
+	claims, err := a.ValidateToken(raw)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != "single_user" {
+		return nil, errors.New("not an admin token")
+	}
+	return claims, nil
+}
+
+// ValidateAgentToken validates raw as a build agent's token and returns the
+// agent ID encoded in its subject.
+func (a *AuthService) ValidateAgentToken(raw string) (string, error) {
+	id, err := a.validateAgentToken(raw)
+	if err != nil {
+		metrics.AuthAttemptsTotal.WithLabelValues("agent", "failure").Inc()
+		return "", err
+	}
+	metrics.AuthAttemptsTotal.WithLabelValues("agent", "success").Inc()
+	return id, nil
+}
+
+func (a *AuthService) validateAgentToken(raw string) (string, error) {
+	claims, err := a.ValidateToken(raw)
+	if err != nil {
+		return "", err
+	}
+	id, ok := strings.CutPrefix(claims.Subject, agentSubjectPrefix)
+	if !ok {
+		return "", errors.New("not an agent token")
+	}
+	return id, nil
+}
@@ -0,0 +1,143 @@
+// Package download coalesces concurrent fetches of the same object into a
+// single read from the backing store, for large artifacts (e.g. launcher
+// builds) that many clients may request at once right after a publish.
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// FetchFunc streams the requested object from its backing store. It is
+// called at most once per group, regardless of how many callers join it,
+// and the returned ReadCloser is closed once fully read.
+type FetchFunc func(ctx context.Context) (io.ReadCloser, error)
+
+// Coalescer deduplicates concurrent Fetch calls for the same key into one
+// underlying FetchFunc invocation, materializing it to a temp file that
+// every caller gets its own *os.File handle on. The zero value is not
+// usable; construct with New.
+type Coalescer struct {
+	tempDir string
+
+	mu     sync.Mutex
+	groups map[string]*group
+}
+
+// group tracks one in-flight or completed coalesced download. Callers that
+// join before the fetch finishes block on ready; callers that join after it
+// finishes proceed immediately and read err/path/size directly, which is
+// safe since both are only written before ready is closed.
+type group struct {
+	ready chan struct{}
+	path  string
+	size  int64
+	err   error
+	refs  int32
+}
+
+func New(tempDir string) *Coalescer {
+	return &Coalescer{tempDir: tempDir, groups: make(map[string]*group)}
+}
+
+// Fetch returns a Result for key, running fetch at most once across every
+// concurrent caller sharing it. joined reports how many callers (including
+// this one) coalesced onto the same underlying fetch, for logging/metrics;
+// it is 1 when this caller triggered the fetch alone.
+func (c *Coalescer) Fetch(ctx context.Context, key string, fetch FetchFunc) (res *Result, joined int, err error) {
+	c.mu.Lock()
+	g, inflight := c.groups[key]
+	if !inflight {
+		g = &group{ready: make(chan struct{})}
+		c.groups[key] = g
+		atomic.AddInt32(&g.refs, 1)
+		c.mu.Unlock()
+		go c.run(ctx, key, g, fetch)
+	} else {
+		atomic.AddInt32(&g.refs, 1)
+		c.mu.Unlock()
+	}
+
+	<-g.ready
+	joined = int(atomic.LoadInt32(&g.refs))
+	if g.err != nil {
+		g.release(c, key)
+		return nil, joined, g.err
+	}
+
+	f, err := os.Open(g.path)
+	if err != nil {
+		g.release(c, key)
+		return nil, joined, err
+	}
+	return &Result{file: f, size: g.size, group: g, coalescer: c, key: key}, joined, nil
+}
+
+func (c *Coalescer) run(ctx context.Context, key string, g *group, fetch FetchFunc) {
+	defer close(g.ready)
+
+	rc, err := fetch(ctx)
+	if err != nil {
+		g.err = err
+		return
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(c.tempDir, "download-*")
+	if err != nil {
+		g.err = err
+		return
+	}
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, rc)
+	if err != nil {
+		os.Remove(tmp.Name())
+		g.err = fmt.Errorf("stream to temp file: %w", err)
+		return
+	}
+	g.path = tmp.Name()
+	g.size = size
+}
+
+// release drops one caller's reference to g, removing it from the
+// Coalescer's group map and deleting its temp file once every caller that
+// joined has released it.
+func (g *group) release(c *Coalescer, key string) {
+	if atomic.AddInt32(&g.refs, -1) != 0 {
+		return
+	}
+	c.mu.Lock()
+	if c.groups[key] == g {
+		delete(c.groups, key)
+	}
+	c.mu.Unlock()
+	if g.path != "" {
+		os.Remove(g.path)
+	}
+}
+
+// Result is one caller's handle on a coalesced download. Every caller that
+// joined the same Fetch gets an independent *os.File positioned at offset 0,
+// so they can read concurrently without interfering with each other. Close
+// must be called exactly once when the caller is done with it.
+type Result struct {
+	file      *os.File
+	size      int64
+	group     *group
+	coalescer *Coalescer
+	key       string
+}
+
+func (r *Result) File() *os.File { return r.file }
+func (r *Result) Size() int64    { return r.size }
+
+func (r *Result) Close() error {
+	err := r.file.Close()
+	r.group.release(r.coalescer, r.key)
+	return err
+}
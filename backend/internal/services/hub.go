@@ -0,0 +1,106 @@
+package services
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// Hub fans out JSON messages (build logs, notifications) to connected
+// WebSocket clients authenticated via the same JWT used by the REST API.
+type Hub struct {
+	auth   *AuthService
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	clients map[*websocket.Conn]struct{}
+
+	broadcast  chan any
+	register   chan *websocket.Conn
+	unregister chan *websocket.Conn
+}
+
+func NewHub(logger *slog.Logger, auth *AuthService) *Hub {
+	return &Hub{
+		auth:       auth,
+		logger:     logger,
+		clients:    make(map[*websocket.Conn]struct{}),
+		broadcast:  make(chan any, 64),
+		register:   make(chan *websocket.Conn),
+		unregister: make(chan *websocket.Conn),
+	}
+}
+
+// Run processes connection lifecycle and broadcast events. It blocks and is
+// meant to be started in its own goroutine.
+func (h *Hub) Run() {
+	for {
+		select {
+		case conn := <-h.register:
+			h.mu.Lock()
+			h.clients[conn] = struct{}{}
+			h.mu.Unlock()
+		case conn := <-h.unregister:
+			h.mu.Lock()
+			if _, ok := h.clients[conn]; ok {
+				delete(h.clients, conn)
+				conn.Close()
+			}
+			h.mu.Unlock()
+		case msg := <-h.broadcast:
+			h.mu.Lock()
+			for conn := range h.clients {
+				if err := conn.WriteJSON(msg); err != nil {
+					h.logger.Warn("failed to write to websocket client", "error", err)
+					delete(h.clients, conn)
+					conn.Close()
+				}
+			}
+			h.mu.Unlock()
+		}
+	}
+}
+
+// Broadcast enqueues msg to be sent to every connected client as JSON.
+func (h *Hub) Broadcast(msg any) {
+	select {
+	case h.broadcast <- msg:
+	default:
+		h.logger.Warn("dropping broadcast message: buffer full")
+	}
+}
+
+// HandleWebSocket upgrades the request to a WebSocket connection after
+// validating the "token" query parameter against the admin JWT.
+func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "missing token", http.StatusUnauthorized)
+		return
+	}
+	if _, err := h.auth.ValidateAdminToken(token); err != nil {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.logger.Warn("websocket upgrade failed", "error", err)
+		return
+	}
+
+	h.register <- conn
+	defer func() { h.unregister <- conn }()
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
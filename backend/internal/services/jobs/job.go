@@ -0,0 +1,67 @@
+package jobs
+
+import "time"
+
+// Status is the lifecycle state of a Job.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// Kind identifies what a Job actually builds.
+const (
+	KindBuildAll      = "build_all"
+	KindBuildInstance = "build_instance"
+)
+
+// Job is a single enqueued (or finished) build. A "build_all" job never runs
+// the builder itself: it only groups the "build_instance" children fanned out
+// for every instance at enqueue time, and its Status and Error are derived
+// from those children by the Manager; it has no ExitCode of its own.
+type Job struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Instance   string     `json:"instance,omitempty"`
+	ParentID   string     `json:"parent_id,omitempty"`
+	Status     Status     `json:"status"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Duration returns how long the job has been (or was) running. It is zero
+// for jobs that have not started yet.
+func (j *Job) Duration() time.Duration {
+	if j.StartedAt == nil {
+		return 0
+	}
+	end := time.Now()
+	if j.FinishedAt != nil {
+		end = *j.FinishedAt
+	}
+	return end.Sub(*j.StartedAt)
+}
+
+func (j *Job) clone() *Job {
+	c := *j
+	if j.ExitCode != nil {
+		code := *j.ExitCode
+		c.ExitCode = &code
+	}
+	if j.StartedAt != nil {
+		t := *j.StartedAt
+		c.StartedAt = &t
+	}
+	if j.FinishedAt != nil {
+		t := *j.FinishedAt
+		c.FinishedAt = &t
+	}
+	return &c
+}
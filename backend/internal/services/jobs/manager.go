@@ -0,0 +1,460 @@
+// Package jobs implements a small persisted job queue for instance builds.
+// It exists so that rebuilding one instance doesn't force a full rebuild of
+// every other instance, and so that an operator can see build history and
+// cancel a build instead of only knowing whether "something" is running.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/logstream"
+)
+
+// Builder runs the instance_builder for the given instances. An empty/nil
+// names slice means "build everything". out receives every stdout/stderr
+// line as its own Write call. It is satisfied by *services.RunnerService.
+type Builder interface {
+	RunInstances(ctx context.Context, names []string, out io.Writer) error
+}
+
+// Manager enqueues, persists and runs build jobs. Jobs are executed by a
+// small worker pool; by default (and normally) that pool has a single
+// worker, matching the builder's own assumption that only one build touches
+// GeneratedDir/WorkdirDir at a time. Raising JobWorkerConcurrency only helps
+// once the configured Builder can tolerate concurrent invocations.
+type Manager struct {
+	mu              sync.Mutex
+	path            string
+	jobs            map[string]*Job
+	order           []string
+	keepPerInstance int
+
+	queue   chan string
+	cancels map[string]context.CancelFunc
+	writers map[string]*logstream.LineWriter
+
+	logsDir     string
+	maxLogBytes int
+	builder     Builder
+}
+
+// NewManager loads any persisted jobs from path, marks jobs that were left
+// running or queued by a previous process as failed (they cannot be resumed
+// safely), and starts workers consuming the queue. Finished jobs' logs are
+// flushed to "<logsDir>/<job_id>.log"; maxLogBytes bounds how much of a
+// still-running job's log is kept in memory for live subscribers.
+func NewManager(path string, builder Builder, logsDir string, keepPerInstance, workers, maxLogBytes int) (*Manager, error) {
+	if path == "" {
+		return nil, errors.New("jobs file path is required")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if maxLogBytes <= 0 {
+		maxLogBytes = 2 << 20
+	}
+
+	m := &Manager{
+		path:            path,
+		jobs:            make(map[string]*Job),
+		keepPerInstance: keepPerInstance,
+		queue:           make(chan string, 4096),
+		cancels:         make(map[string]context.CancelFunc),
+		writers:         make(map[string]*logstream.LineWriter),
+		logsDir:         logsDir,
+		maxLogBytes:     maxLogBytes,
+		builder:         builder,
+	}
+
+	if err := os.MkdirAll(logsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create logs dir: %w", err)
+	}
+
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < workers; i++ {
+		go m.worker()
+	}
+	return m, nil
+}
+
+func (m *Manager) load() error {
+	raw, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read jobs: %w", err)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var stored []*Job
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return fmt.Errorf("decode jobs: %w", err)
+	}
+
+	now := time.Now()
+	for _, job := range stored {
+		if job.Status == StatusQueued || job.Status == StatusRunning {
+			job.Status = StatusFailed
+			job.Error = "interrupted by server restart"
+			job.FinishedAt = &now
+		}
+		m.jobs[job.ID] = job
+		m.order = append(m.order, job.ID)
+	}
+	return m.persistLocked()
+}
+
+func (m *Manager) persistLocked() error {
+	jobs := make([]*Job, 0, len(m.order))
+	for _, id := range m.order {
+		jobs = append(jobs, m.jobs[id])
+	}
+	raw, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode jobs: %w", err)
+	}
+	if err := os.WriteFile(m.path, raw, 0o644); err != nil {
+		return fmt.Errorf("write jobs: %w", err)
+	}
+	return nil
+}
+
+// Enqueue schedules a single build_instance job.
+func (m *Manager) Enqueue(instance string) (*Job, error) {
+	return m.enqueue(KindBuildInstance, instance, "")
+}
+
+// EnqueueAll schedules a build_all parent job plus one build_instance child
+// per instance name, so an operator who only changed one modpack isn't
+// forced to wait for (or re-run) every other instance's build.
+func (m *Manager) EnqueueAll(instanceNames []string) (*Job, []*Job, error) {
+	parent, err := m.enqueue(KindBuildAll, "", "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	children := make([]*Job, 0, len(instanceNames))
+	for _, name := range instanceNames {
+		child, err := m.enqueue(KindBuildInstance, name, parent.ID)
+		if err != nil {
+			return parent, children, err
+		}
+		children = append(children, child)
+	}
+
+	go m.awaitChildren(parent.ID, children)
+	return parent, children, nil
+}
+
+func (m *Manager) enqueue(kind, instance, parentID string) (*Job, error) {
+	job := &Job{
+		ID:        uuid.NewString(),
+		Kind:      kind,
+		Instance:  instance,
+		ParentID:  parentID,
+		Status:    StatusQueued,
+		CreatedAt: time.Now(),
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.order = append(m.order, job.ID)
+	err := m.persistLocked()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if kind != KindBuildAll {
+		m.queue <- job.ID
+	}
+	return job.clone(), nil
+}
+
+// awaitChildren marks a build_all parent finished once every fanned-out
+// child has reached a terminal state, rolling up the first failure.
+func (m *Manager) awaitChildren(parentID string, children []*Job) {
+	ids := make([]string, len(children))
+	for i, c := range children {
+		ids[i] = c.ID
+	}
+
+	for {
+		time.Sleep(500 * time.Millisecond)
+
+		m.mu.Lock()
+		status := StatusSucceeded
+		var failErr string
+		done := true
+		for _, id := range ids {
+			job := m.jobs[id]
+			switch job.Status {
+			case StatusQueued, StatusRunning:
+				done = false
+			case StatusFailed:
+				status = StatusFailed
+				if failErr == "" {
+					failErr = job.Error
+				}
+			case StatusCanceled:
+				if status != StatusFailed {
+					status = StatusCanceled
+				}
+			}
+		}
+		if !done {
+			m.mu.Unlock()
+			continue
+		}
+
+		parent := m.jobs[parentID]
+		now := time.Now()
+		parent.Status = status
+		parent.Error = failErr
+		parent.FinishedAt = &now
+		err := m.persistLocked()
+		m.mu.Unlock()
+		if err != nil {
+			return
+		}
+		return
+	}
+}
+
+func (m *Manager) worker() {
+	for id := range m.queue {
+		m.run(id)
+	}
+}
+
+func (m *Manager) run(id string) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok || job.Status != StatusQueued {
+		m.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	job.Status = StatusRunning
+	job.StartedAt = &now
+	err := m.persistLocked()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[id] = cancel
+	writer := logstream.NewLineWriter(m.maxLogBytes)
+	m.writers[id] = writer
+	m.mu.Unlock()
+	if err != nil {
+		cancel()
+	}
+
+	var names []string
+	if job.Kind == KindBuildInstance {
+		names = []string{job.Instance}
+	}
+	runErr := m.builder.RunInstances(ctx, names, writer)
+	writer.Close()
+	m.flushLog(id, writer)
+
+	m.mu.Lock()
+	delete(m.cancels, id)
+	delete(m.writers, id)
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled) && runErr != nil:
+		job.Status = StatusCanceled
+	case runErr != nil:
+		job.Status = StatusFailed
+		job.Error = runErr.Error()
+	default:
+		job.Status = StatusSucceeded
+	}
+	if code, ok := exitCode(runErr); ok {
+		job.ExitCode = &code
+	}
+	m.persistLocked()
+	m.pruneLocked(job.Instance)
+	m.mu.Unlock()
+}
+
+// LogFilePath returns where a finished job's flushed log lives.
+func (m *Manager) LogFilePath(id string) string {
+	return filepath.Join(m.logsDir, id+".log")
+}
+
+func (m *Manager) flushLog(id string, writer *logstream.LineWriter) {
+	lines := writer.Lines()
+	raw := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(m.LogFilePath(id), []byte(raw), 0o644); err != nil {
+		m.mu.Lock()
+		if job, ok := m.jobs[id]; ok && job.Error == "" {
+			job.Error = fmt.Sprintf("failed to flush log: %v", err)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// ReadLogFile returns the flushed log lines for a finished job.
+func (m *Manager) ReadLogFile(id string) ([]string, error) {
+	raw, err := os.ReadFile(m.LogFilePath(id))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(raw), "\n"), "\n"), nil
+}
+
+// Subscribe attaches a live subscriber to a currently running job's log
+// stream, returning a replay of up to the last `tail` lines (tail <= 0 means
+// everything buffered) plus a channel for subsequent lines. ok is false if
+// the job isn't currently running (callers should fall back to
+// ReadLogFile).
+func (m *Manager) Subscribe(id string, tail int) (replay []string, ch <-chan string, unsubscribe func(), ok bool) {
+	m.mu.Lock()
+	writer, exists := m.writers[id]
+	m.mu.Unlock()
+	if !exists {
+		return nil, nil, nil, false
+	}
+
+	subID, replay, ch := writer.Subscribe(tail)
+	return replay, ch, func() { writer.Unsubscribe(subID) }, true
+}
+
+// Latest returns the most recently enqueued job, if any.
+func (m *Manager) Latest() (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.order) == 0 {
+		return nil, false
+	}
+	return m.jobs[m.order[len(m.order)-1]].clone(), true
+}
+
+// exitCoder is implemented by errors that carry a process exit code without
+// being a literal *exec.ExitError, e.g. a remote agent (internal/agentrpc)
+// reporting its own instance_builder invocation's outcome.
+type exitCoder interface {
+	ExitCode() int
+}
+
+func exitCode(err error) (int, bool) {
+	if err == nil {
+		return 0, true
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	var coder exitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode(), true
+	}
+	return 0, false
+}
+
+// pruneLocked keeps at most keepPerInstance finished build_instance jobs per
+// instance, dropping the oldest first. build_all jobs are never pruned by
+// this pass since they have no Instance.
+func (m *Manager) pruneLocked(instance string) {
+	if instance == "" || m.keepPerInstance <= 0 {
+		return
+	}
+
+	var kept []string
+	matching := 0
+	for i := len(m.order) - 1; i >= 0; i-- {
+		id := m.order[i]
+		job := m.jobs[id]
+		if job.Instance != instance || job.Status == StatusQueued || job.Status == StatusRunning {
+			continue
+		}
+		matching++
+		if matching > m.keepPerInstance {
+			delete(m.jobs, id)
+		}
+	}
+	for _, id := range m.order {
+		if _, ok := m.jobs[id]; ok {
+			kept = append(kept, id)
+		}
+	}
+	m.order = kept
+}
+
+// Get returns a single job by ID.
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return job.clone(), true
+}
+
+// List returns jobs newest-first, optionally filtered by instance and/or
+// status.
+func (m *Manager) List(instance string, status Status) []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]*Job, 0, len(m.order))
+	for _, id := range m.order {
+		job := m.jobs[id]
+		if instance != "" && job.Instance != instance {
+			continue
+		}
+		if status != "" && job.Status != status {
+			continue
+		}
+		out = append(out, job.clone())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// Cancel stops a job: a queued job is marked canceled without ever running,
+// a running job has its context canceled so the builder process is killed.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("job not found")
+	}
+
+	switch job.Status {
+	case StatusQueued:
+		now := time.Now()
+		job.Status = StatusCanceled
+		job.FinishedAt = &now
+		return m.persistLocked()
+	case StatusRunning:
+		if cancel, ok := m.cancels[id]; ok {
+			cancel()
+		}
+		return nil
+	default:
+		return fmt.Errorf("job already finished")
+	}
+}
@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockingBuilder's RunInstances blocks until either block is closed (run
+// "succeeds") or ctx is canceled, so tests can deterministically control
+// when a job is running vs. queued.
+type blockingBuilder struct {
+	block chan struct{}
+}
+
+func newBlockingBuilder() *blockingBuilder {
+	return &blockingBuilder{block: make(chan struct{})}
+}
+
+func (b *blockingBuilder) RunInstances(ctx context.Context, names []string, out io.Writer) error {
+	select {
+	case <-b.block:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// immediateBuilder's RunInstances returns success right away.
+type immediateBuilder struct{}
+
+func (immediateBuilder) RunInstances(ctx context.Context, names []string, out io.Writer) error {
+	return nil
+}
+
+func newTestManager(t *testing.T, builder Builder, keepPerInstance int) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+	m, err := NewManager(filepath.Join(dir, "jobs.json"), builder, filepath.Join(dir, "logs"), keepPerInstance, 1, 0)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m
+}
+
+func waitForStatus(t *testing.T, m *Manager, id string, want Status) *Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := m.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Status == want {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := m.Get(id)
+	t.Fatalf("job %s: status = %s, want %s (after timeout)", id, job.Status, want)
+	return nil
+}
+
+func TestManagerCancelQueuedJob(t *testing.T) {
+	builder := newBlockingBuilder()
+	m := newTestManager(t, builder, 0)
+
+	running, err := m.Enqueue("instance-a")
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	waitForStatus(t, m, running.ID, StatusRunning)
+
+	queued, err := m.Enqueue("instance-a")
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	if err := m.Cancel(queued.ID); err != nil {
+		t.Fatalf("Cancel queued job: %v", err)
+	}
+	job := waitForStatus(t, m, queued.ID, StatusCanceled)
+	if job.FinishedAt == nil {
+		t.Fatal("expected FinishedAt to be set for a canceled queued job")
+	}
+
+	close(builder.block)
+	waitForStatus(t, m, running.ID, StatusSucceeded)
+}
+
+func TestManagerCancelRunningJob(t *testing.T) {
+	builder := newBlockingBuilder()
+	m := newTestManager(t, builder, 0)
+
+	job, err := m.Enqueue("instance-a")
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	waitForStatus(t, m, job.ID, StatusRunning)
+
+	if err := m.Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel running job: %v", err)
+	}
+	waitForStatus(t, m, job.ID, StatusCanceled)
+}
+
+func TestManagerCancelFinishedJobErrors(t *testing.T) {
+	m := newTestManager(t, immediateBuilder{}, 0)
+
+	job, err := m.Enqueue("instance-a")
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	waitForStatus(t, m, job.ID, StatusSucceeded)
+
+	if err := m.Cancel(job.ID); err == nil {
+		t.Fatal("expected Cancel on an already-finished job to error")
+	}
+}
+
+func TestManagerPruneKeepsOnlyMostRecentPerInstance(t *testing.T) {
+	const keep = 2
+	m := newTestManager(t, immediateBuilder{}, keep)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		job, err := m.Enqueue("instance-a")
+		if err != nil {
+			t.Fatalf("Enqueue %d: %v", i, err)
+		}
+		waitForStatus(t, m, job.ID, StatusSucceeded)
+		ids = append(ids, job.ID)
+	}
+
+	remaining := m.List("instance-a", "")
+	if len(remaining) != keep {
+		t.Fatalf("List returned %d jobs, want %d", len(remaining), keep)
+	}
+
+	for _, id := range ids[:len(ids)-keep] {
+		if _, ok := m.Get(id); ok {
+			t.Fatalf("expected pruned job %s to be gone", id)
+		}
+	}
+	for _, id := range ids[len(ids)-keep:] {
+		if _, ok := m.Get(id); !ok {
+			t.Fatalf("expected kept job %s to still exist", id)
+		}
+	}
+}
+
+func TestManagerPruneDoesNotTouchBuildAllJobs(t *testing.T) {
+	const keep = 1
+	m := newTestManager(t, immediateBuilder{}, keep)
+
+	parent, children, err := m.EnqueueAll([]string{"instance-a"})
+	if err != nil {
+		t.Fatalf("EnqueueAll: %v", err)
+	}
+	waitForStatus(t, m, children[0].ID, StatusSucceeded)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, ok := m.Get(parent.ID); ok && job.Status != StatusQueued && job.Status != StatusRunning {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, ok := m.Get(parent.ID); !ok {
+		t.Fatal("expected the build_all parent job to survive pruning")
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
+)
+
+// ErrInstallProfileUnsupported is returned by InstallProfile when a loader
+// has no simple metadata-API install profile (e.g. Forge/NeoForge installer
+// profiles live inside a downloadable installer jar, not behind a REST
+// endpoint).
+var ErrInstallProfileUnsupported = errors.New("install profile not available for this loader")
+
+// LoaderResolver resolves everything GetLoadersForVersion/GetLoaderVersions
+// need for one LoaderType, so adding a new loader (LiteLoader, Risugami,
+// Ornithe, ...) means implementing and registering one of these instead of
+// touching the HTTP layer or those two functions.
+type LoaderResolver interface {
+	// Supports reports, without making a network call, whether this loader
+	// could plausibly target mcVersion (e.g. NeoForge only targets 1.20.2+).
+	Supports(mcVersion string) bool
+	// Versions returns the loader versions available for mcVersion. stale
+	// reports whether the result came from the stale-while-revalidate cache.
+	Versions(ctx context.Context, mcVersion string) (versions []string, stale bool, err error)
+	// InstallProfile returns the installer profile document for
+	// mcVersion/loaderVersion, or ErrInstallProfileUnsupported if this
+	// loader doesn't expose one via a simple metadata fetch.
+	InstallProfile(ctx context.Context, mcVersion, loaderVersion string) (json.RawMessage, error)
+}
+
+// loaderOrder is the order loaders are reported in by GetLoadersForVersion,
+// kept stable since loaderResolvers is a map.
+var loaderOrder = []models.LoaderType{
+	models.LoaderVanilla,
+	models.LoaderFabric,
+	models.LoaderForge,
+	models.LoaderNeo,
+	models.LoaderQuilt,
+}
+
+var loaderResolvers = map[models.LoaderType]LoaderResolver{
+	models.LoaderVanilla: vanillaResolver{},
+	models.LoaderFabric:  &fabricLikeResolver{metaBase: fabricMetaBaseURL, breaker: fabricBreaker, cache: fabricCache},
+	models.LoaderForge:   forgeResolver{},
+	models.LoaderNeo:     neoforgeResolver{},
+	models.LoaderQuilt:   &fabricLikeResolver{metaBase: quiltMetaBaseURL, breaker: quiltBreaker, cache: quiltCache},
+}
+
+// RegisteredLoaders returns every loader type with a registered resolver, in
+// a stable order, for validating BuilderInstance.LoaderName at spec-parse
+// time.
+func RegisteredLoaders() []models.LoaderType {
+	out := make([]models.LoaderType, len(loaderOrder))
+	copy(out, loaderOrder)
+	return out
+}
+
+// IsRegisteredLoader reports whether loader has a registered resolver.
+func IsRegisteredLoader(loader models.LoaderType) bool {
+	_, ok := loaderResolvers[loader]
+	return ok
+}
+
+// vanillaResolver treats the Minecraft version itself as its only "loader
+// version", present only when it's a real vanilla release/snapshot.
+type vanillaResolver struct{}
+
+func (vanillaResolver) Supports(string) bool { return true }
+
+func (vanillaResolver) Versions(ctx context.Context, mcVersion string) (versions []string, stale bool, err error) {
+	vanilla, stale, err := GetVanillaVersions(ctx, "")
+	if err != nil {
+		return nil, false, err
+	}
+	if slices.Contains(vanilla, mcVersion) {
+		return []string{mcVersion}, stale, nil
+	}
+	return nil, stale, nil
+}
+
+func (vanillaResolver) InstallProfile(context.Context, string, string) (json.RawMessage, error) {
+	return nil, ErrInstallProfileUnsupported
+}
+
+// fabricLikeResolver backs any loader whose meta API matches Fabric's shape
+// (Fabric itself and Quilt, which forked it), parameterized by metaBase.
+type fabricLikeResolver struct {
+	metaBase string
+	breaker  *CircuitBreaker
+	cache    *staleCache[[]string]
+}
+
+func (r *fabricLikeResolver) Supports(string) bool { return true }
+
+func (r *fabricLikeResolver) Versions(ctx context.Context, mcVersion string) (versions []string, stale bool, err error) {
+	return fetchResilient(ctx, r.breaker, r.cache, mcVersion, func(ctx context.Context) ([]string, error) {
+		return fetchFabricLikeLoaderVersions(ctx, r.metaBase, mcVersion)
+	})
+}
+
+func (r *fabricLikeResolver) InstallProfile(ctx context.Context, mcVersion, loaderVersion string) (json.RawMessage, error) {
+	url := r.metaBase + mcVersion + "/" + loaderVersion + "/profile/json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newUpstreamError(resp, "loader install profile error")
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(body), nil
+}
+
+// forgeResolver wraps the existing Forge maven-metadata lookup.
+type forgeResolver struct{}
+
+func (forgeResolver) Supports(string) bool { return true }
+
+func (forgeResolver) Versions(ctx context.Context, mcVersion string) (versions []string, stale bool, err error) {
+	return getForgeLoaderVersions(ctx, mcVersion)
+}
+
+func (forgeResolver) InstallProfile(context.Context, string, string) (json.RawMessage, error) {
+	return nil, ErrInstallProfileUnsupported
+}
+
+// neoforgeResolver wraps the existing NeoForge maven-metadata lookup.
+// Supports is a cheap local check, which GetLoadersForVersion uses to skip
+// the upstream fetch for Minecraft versions mcToNeoforgePrefix cannot map
+// (fewer than two dot-separated parts, e.g. snapshot ids). Releases before
+// 1.20.2 still pass it; the fetch then simply matches no NeoForge version.
+type neoforgeResolver struct{}
+
+func (neoforgeResolver) Supports(mcVersion string) bool {
+	return mcToNeoforgePrefix(mcVersion) != ""
+}
+
+func (neoforgeResolver) Versions(ctx context.Context, mcVersion string) (versions []string, stale bool, err error) {
+	return getNeoforgeLoaderVersions(ctx, mcVersion)
+}
+
+func (neoforgeResolver) InstallProfile(context.Context, string, string) (json.RawMessage, error) {
+	return nil, ErrInstallProfileUnsupported
+}
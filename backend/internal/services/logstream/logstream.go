@@ -0,0 +1,125 @@
+// Package logstream fans a build's line-oriented output out to any number
+// of live subscribers while keeping a bounded ring buffer so late
+// subscribers can replay what already happened.
+package logstream
+
+import "sync"
+
+const sentinelLine = "... [truncated: oldest log lines dropped to stay under the size cap] ..."
+
+// LineWriter implements io.Writer so it can sit directly on an exec.Cmd's
+// stdout/stderr pipes alongside the existing log broadcaster. Each Write is
+// treated as a single already-split line (without its trailing newline).
+type LineWriter struct {
+	maxBytes int
+
+	mu          sync.Mutex
+	lines       []string
+	bytes       int
+	truncated   bool
+	closed      bool
+	subscribers map[int]chan string
+	nextSub     int
+}
+
+// NewLineWriter returns a LineWriter that keeps at most maxBytes of buffered
+// log text, dropping the oldest lines first once that cap is exceeded.
+func NewLineWriter(maxBytes int) *LineWriter {
+	return &LineWriter{
+		maxBytes:    maxBytes,
+		subscribers: make(map[int]chan string),
+	}
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.WriteLine(string(p))
+	return len(p), nil
+}
+
+// WriteLine appends a line to the ring buffer and fans it out to every
+// current subscriber. Slow subscribers drop lines rather than block the
+// build.
+func (w *LineWriter) WriteLine(line string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+
+	w.lines = append(w.lines, line)
+	w.bytes += len(line) + 1
+	for w.bytes > w.maxBytes {
+		// Once truncated, w.lines[0] is the sentinel: it must never be
+		// dropped itself, so the oldest droppable line sits one past it.
+		dropIdx := 0
+		if w.truncated {
+			dropIdx = 1
+		}
+		if dropIdx >= len(w.lines) {
+			break
+		}
+		dropped := w.lines[dropIdx]
+		w.lines = append(w.lines[:dropIdx], w.lines[dropIdx+1:]...)
+		w.bytes -= len(dropped) + 1
+		if !w.truncated {
+			w.truncated = true
+			w.lines = append([]string{sentinelLine}, w.lines...)
+			w.bytes += len(sentinelLine) + 1
+		}
+	}
+
+	for _, ch := range w.subscribers {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a replay of up to the last `tail` buffered lines (tail
+// <= 0 means the entire buffer) plus a channel fed with every line written
+// afterwards. The caller must call Unsubscribe(id) once done.
+func (w *LineWriter) Subscribe(tail int) (id int, replay []string, ch <-chan string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	start := 0
+	if tail > 0 && tail < len(w.lines) {
+		start = len(w.lines) - tail
+	}
+	replay = append([]string(nil), w.lines[start:]...)
+
+	w.nextSub++
+	id = w.nextSub
+	sub := make(chan string, 256)
+	w.subscribers[id] = sub
+	return id, replay, sub
+}
+
+// Unsubscribe detaches and closes a subscriber's channel.
+func (w *LineWriter) Unsubscribe(id int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if ch, ok := w.subscribers[id]; ok {
+		delete(w.subscribers, id)
+		close(ch)
+	}
+}
+
+// Close stops accepting new lines and disconnects every subscriber.
+func (w *LineWriter) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	for id, ch := range w.subscribers {
+		delete(w.subscribers, id)
+		close(ch)
+	}
+}
+
+// Lines returns a snapshot of every buffered line, oldest first.
+func (w *LineWriter) Lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.lines...)
+}
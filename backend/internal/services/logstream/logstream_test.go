@@ -0,0 +1,98 @@
+package logstream
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestWriteLineTruncatesUnderByteCap writes enough lines to force repeated
+// drops within a single Write-triggered truncation pass and checks that the
+// byte accounting never goes negative and the sentinel survives.
+func TestWriteLineTruncatesUnderByteCap(t *testing.T) {
+	const maxBytes = 200
+	w := NewLineWriter(maxBytes)
+
+	for i := 0; i < 50; i++ {
+		w.WriteLine(strings.Repeat("x", 8))
+	}
+
+	w.mu.Lock()
+	bytes, lines, truncated := w.bytes, append([]string(nil), w.lines...), w.truncated
+	w.mu.Unlock()
+
+	if !truncated {
+		t.Fatalf("expected truncated to be true")
+	}
+	if bytes < 0 {
+		t.Fatalf("tracked byte count went negative: %d", bytes)
+	}
+	if bytes > maxBytes {
+		t.Fatalf("tracked byte count %d exceeds maxBytes %d", bytes, maxBytes)
+	}
+	if len(lines) == 0 || lines[0] != sentinelLine {
+		t.Fatalf("expected sentinel as first line, got %v", lines)
+	}
+	if n := countOccurrences(lines, sentinelLine); n != 1 {
+		t.Fatalf("expected exactly one sentinel line, found %d in %v", n, lines)
+	}
+}
+
+// TestWriteLineSingleWriteDropsMultipleLines covers a single Write needing
+// to drop more than one buffered line to get back under maxBytes, which is
+// what let the sentinel's own bytes go unaccounted for.
+func TestWriteLineSingleWriteDropsMultipleLines(t *testing.T) {
+	w := NewLineWriter(120)
+	w.WriteLine("aaaa")
+	w.WriteLine("bbbb")
+	w.WriteLine("cccc")
+
+	// This single line alone exceeds maxBytes once combined with the
+	// existing buffer, forcing the truncation loop to drop every prior line
+	// (and insert the sentinel) in one pass.
+	w.WriteLine(strings.Repeat("z", 110))
+
+	w.mu.Lock()
+	bytes, lines := w.bytes, append([]string(nil), w.lines...)
+	w.mu.Unlock()
+
+	if bytes < 0 {
+		t.Fatalf("tracked byte count went negative: %d", bytes)
+	}
+	if lines[0] != sentinelLine {
+		t.Fatalf("expected sentinel as first line, got %v", lines)
+	}
+}
+
+func countOccurrences(lines []string, s string) int {
+	n := 0
+	for _, l := range lines {
+		if l == s {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSubscribeReplaysTail(t *testing.T) {
+	w := NewLineWriter(1 << 20)
+	w.WriteLine("one")
+	w.WriteLine("two")
+	w.WriteLine("three")
+
+	id, replay, ch := w.Subscribe(2)
+	defer w.Unsubscribe(id)
+
+	if got := strings.Join(replay, ","); got != "two,three" {
+		t.Fatalf("replay = %v, want [two three]", replay)
+	}
+
+	w.WriteLine("four")
+	select {
+	case line := <-ch:
+		if line != "four" {
+			t.Fatalf("got line %q, want four", line)
+		}
+	default:
+		t.Fatalf("expected a line on the subscriber channel")
+	}
+}
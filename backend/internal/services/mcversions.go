@@ -4,11 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +18,7 @@ import (
 const (
 	mojangManifestURL   = "https://piston-meta.mojang.com/mc/game/version_manifest_v2.json"
 	fabricMetaBaseURL   = "https://meta.fabricmc.net/v2/versions/loader/"
+	quiltMetaBaseURL    = "https://meta.quiltmc.org/v3/versions/loader/"
 	forgeMetadataURL    = "https://files.minecraftforge.net/net/minecraftforge/forge/maven-metadata.json"
 	neoforgeMetadataURL = "https://maven.neoforged.net/releases/net/neoforged/neoforge/maven-metadata.xml"
 )
@@ -26,7 +27,48 @@ var httpClient = &http.Client{
 	Timeout: 10 * time.Second,
 }
 
-func GetVanillaVersions(ctx context.Context, versionType string) ([]string, error) {
+// Each upstream (Mojang, Fabric, Quilt, Forge, NeoForge) gets its own
+// circuit breaker and stale-while-revalidate cache, so a failure in one
+// doesn't trip the others and a cached response can be served in its place.
+// Cache keys are the version the data is scoped to ("" for upstreams that
+// return everything in one document).
+var (
+	mojangBreaker   = newCircuitBreaker("mojang")
+	fabricBreaker   = newCircuitBreaker("fabric")
+	quiltBreaker    = newCircuitBreaker("quilt")
+	forgeBreaker    = newCircuitBreaker("forge")
+	neoforgeBreaker = newCircuitBreaker("neoforge")
+
+	mojangCache   = newStaleCache[[]mojangVersionEntry]()
+	fabricCache   = newStaleCache[[]string]()
+	quiltCache    = newStaleCache[[]string]()
+	forgeCache    = newStaleCache[map[string][]string]()
+	neoforgeCache = newStaleCache[[]string]()
+)
+
+type mojangVersionEntry struct {
+	ID   string
+	Type string
+}
+
+// GetVanillaVersions returns vanilla version ids, optionally filtered by
+// versionType ("release", "snapshot", or "" for all). stale reports whether
+// the result came from the cache after the upstream fetch failed.
+func GetVanillaVersions(ctx context.Context, versionType string) (versions []string, stale bool, err error) {
+	entries, stale, err := fetchResilient(ctx, mojangBreaker, mojangCache, "manifest", fetchMojangManifest)
+	if err != nil {
+		return nil, false, err
+	}
+	out := make([]string, 0, len(entries))
+	for _, v := range entries {
+		if versionType == "" || strings.EqualFold(v.Type, versionType) {
+			out = append(out, v.ID)
+		}
+	}
+	return out, stale, nil
+}
+
+func fetchMojangManifest(ctx context.Context) ([]mojangVersionEntry, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mojangManifestURL, nil)
 	if err != nil {
 		return nil, err
@@ -37,7 +79,7 @@ func GetVanillaVersions(ctx context.Context, versionType string) ([]string, erro
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("mojang manifest error: %s", resp.Status)
+		return nil, newUpstreamError(resp, "mojang manifest error")
 	}
 	var payload struct {
 		Versions []struct {
@@ -48,58 +90,70 @@ func GetVanillaVersions(ctx context.Context, versionType string) ([]string, erro
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
 		return nil, err
 	}
-	out := make([]string, 0, len(payload.Versions))
-	for _, v := range payload.Versions {
-		if versionType == "" || strings.EqualFold(v.Type, versionType) {
-			out = append(out, v.ID)
-		}
+	out := make([]mojangVersionEntry, len(payload.Versions))
+	for i, v := range payload.Versions {
+		out[i] = mojangVersionEntry{ID: v.ID, Type: v.Type}
 	}
 	return out, nil
 }
 
-func GetLoadersForVersion(ctx context.Context, version string) ([]models.LoaderType, error) {
-	vanilla, err := GetVanillaVersions(ctx, "")
+// GetLoadersForVersion returns which loaders are available for version,
+// driven by the LoaderResolver registry (see loaders.go) so adding a loader
+// there is enough to surface it here too. stale reports whether any of the
+// underlying upstream lookups fell back to a cached response.
+//
+// Vanilla is resolved first and its error, if any, aborts the whole call
+// (there's no point listing loaders for an unverifiable Minecraft version);
+// every other loader's lookup failure is treated as "not available" rather
+// than failing the request, since one loader's upstream being down
+// shouldn't hide the others.
+func GetLoadersForVersion(ctx context.Context, version string) (loaders []models.LoaderType, stale bool, err error) {
+	vanillaVersions, vanillaStale, err := loaderResolvers[models.LoaderVanilla].Versions(ctx, version)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	loaders := make([]models.LoaderType, 0, 4)
-	if slices.Contains(vanilla, version) {
+	stale = vanillaStale
+	if len(vanillaVersions) > 0 {
 		loaders = append(loaders, models.LoaderVanilla)
 	}
-	if ok, _ := fabricHasLoader(ctx, version); ok {
-		loaders = append(loaders, models.LoaderFabric)
-	}
-	if ok, _ := forgeHasLoader(ctx, version); ok {
-		loaders = append(loaders, models.LoaderForge)
-	}
-	if ok, _ := neoforgeHasLoader(ctx, version); ok {
-		loaders = append(loaders, models.LoaderNeo)
-	}
-	return loaders, nil
-}
 
-func GetLoaderVersions(ctx context.Context, version string, loader models.LoaderType) ([]string, error) {
-	switch loader {
-	case models.LoaderVanilla:
-		return []string{version}, nil
-	case models.LoaderFabric:
-		return getFabricLoaderVersions(ctx, version)
-	case models.LoaderForge:
-		return getForgeLoaderVersions(ctx, version)
-	case models.LoaderNeo:
-		return getNeoforgeLoaderVersions(ctx, version)
-	default:
-		return nil, errors.New("unknown loader")
+	for _, lt := range loaderOrder {
+		if lt == models.LoaderVanilla {
+			continue
+		}
+		resolver := loaderResolvers[lt]
+		if !resolver.Supports(version) {
+			continue
+		}
+		versions, s, _ := resolver.Versions(ctx, version)
+		if len(versions) > 0 {
+			loaders = append(loaders, lt)
+			stale = stale || s
+		}
 	}
+	return loaders, stale, nil
 }
 
-func fabricHasLoader(ctx context.Context, version string) (bool, error) {
-	versions, err := getFabricLoaderVersions(ctx, version)
-	return len(versions) > 0, err
+// GetLoaderVersions returns the loader versions available for version.
+// Vanilla is special-cased, as it has always been: it echoes version back
+// without validating it, since its validity is whatever GetLoadersForVersion
+// already told the caller. Every other loader goes through its registered
+// resolver (see loaders.go).
+func GetLoaderVersions(ctx context.Context, version string, loader models.LoaderType) (versions []string, stale bool, err error) {
+	if loader == models.LoaderVanilla {
+		return []string{version}, false, nil
+	}
+	resolver, ok := loaderResolvers[loader]
+	if !ok {
+		return nil, false, fmt.Errorf("unknown loader: %s", loader)
+	}
+	return resolver.Versions(ctx, version)
 }
 
-func getFabricLoaderVersions(ctx context.Context, version string) ([]string, error) {
-	url := fabricMetaBaseURL + version
+// fetchFabricLikeLoaderVersions fetches loader versions from a Fabric-meta
+// compatible API (Fabric and Quilt share the same response shape).
+func fetchFabricLikeLoaderVersions(ctx context.Context, metaBase, version string) ([]string, error) {
+	url := metaBase + version
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
@@ -113,7 +167,7 @@ func getFabricLoaderVersions(ctx context.Context, version string) ([]string, err
 		return []string{}, nil
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("fabric meta error: %s", resp.Status)
+		return nil, newUpstreamError(resp, "loader meta error")
 	}
 	var payload []struct {
 		Loader struct {
@@ -137,19 +191,10 @@ func getFabricLoaderVersions(ctx context.Context, version string) ([]string, err
 	return out, nil
 }
 
-func forgeHasLoader(ctx context.Context, version string) (bool, error) {
-	data, err := fetchForgeMetadata(ctx)
-	if err != nil {
-		return false, err
-	}
-	_, ok := data[version]
-	return ok, nil
-}
-
-func getForgeLoaderVersions(ctx context.Context, version string) ([]string, error) {
-	data, err := fetchForgeMetadata(ctx)
+func getForgeLoaderVersions(ctx context.Context, version string) (versions []string, stale bool, err error) {
+	data, stale, err := fetchForgeMetadata(ctx)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	items := data[version]
 	out := make([]string, 0, len(items))
@@ -167,10 +212,14 @@ func getForgeLoaderVersions(ctx context.Context, version string) ([]string, erro
 		}
 		return strings.Compare(b, a)
 	})
-	return out, nil
+	return out, stale, nil
+}
+
+func fetchForgeMetadata(ctx context.Context) (map[string][]string, bool, error) {
+	return fetchResilient(ctx, forgeBreaker, forgeCache, "metadata", fetchForgeMetadataUpstream)
 }
 
-func fetchForgeMetadata(ctx context.Context) (map[string][]string, error) {
+func fetchForgeMetadataUpstream(ctx context.Context) (map[string][]string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, forgeMetadataURL, nil)
 	if err != nil {
 		return nil, err
@@ -181,7 +230,7 @@ func fetchForgeMetadata(ctx context.Context) (map[string][]string, error) {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("forge metadata error: %s", resp.Status)
+		return nil, newUpstreamError(resp, "forge metadata error")
 	}
 	var payload map[string][]string
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
@@ -190,19 +239,14 @@ func fetchForgeMetadata(ctx context.Context) (map[string][]string, error) {
 	return payload, nil
 }
 
-func neoforgeHasLoader(ctx context.Context, version string) (bool, error) {
-	versions, err := getNeoforgeLoaderVersions(ctx, version)
-	return len(versions) > 0, err
-}
-
-func getNeoforgeLoaderVersions(ctx context.Context, version string) ([]string, error) {
+func getNeoforgeLoaderVersions(ctx context.Context, version string) (versions []string, stale bool, err error) {
 	prefix := mcToNeoforgePrefix(version)
 	if prefix == "" {
-		return nil, nil
+		return nil, false, nil
 	}
-	items, err := fetchNeoforgeVersions(ctx)
+	items, stale, err := fetchNeoforgeVersions(ctx)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	matched := make([]string, 0)
 	for _, item := range items {
@@ -210,10 +254,14 @@ func getNeoforgeLoaderVersions(ctx context.Context, version string) ([]string, e
 			matched = append(matched, item)
 		}
 	}
-	return matched, nil
+	return matched, stale, nil
+}
+
+func fetchNeoforgeVersions(ctx context.Context) ([]string, bool, error) {
+	return fetchResilient(ctx, neoforgeBreaker, neoforgeCache, "versions", fetchNeoforgeVersionsUpstream)
 }
 
-func fetchNeoforgeVersions(ctx context.Context) ([]string, error) {
+func fetchNeoforgeVersionsUpstream(ctx context.Context) ([]string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, neoforgeMetadataURL, nil)
 	if err != nil {
 		return nil, err
@@ -224,7 +272,7 @@ func fetchNeoforgeVersions(ctx context.Context) ([]string, error) {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("neoforge metadata error: %s", resp.Status)
+		return nil, newUpstreamError(resp, "neoforge metadata error")
 	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -253,3 +301,30 @@ func mcToNeoforgePrefix(version string) string {
 	}
 	return ""
 }
+
+// newUpstreamError wraps a non-2xx upstream response, capturing its status
+// code and Retry-After (if any) so callUpstream can decide whether and how
+// long to wait before retrying.
+func newUpstreamError(resp *http.Response, msg string) *upstreamError {
+	return &upstreamError{
+		statusCode: resp.StatusCode,
+		retryAfter: parseRetryAfter(resp),
+		err:        fmt.Errorf("%s: %s", msg, resp.Status),
+	}
+}
+
+func parseRetryAfter(resp *http.Response) time.Duration {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if when, err := http.ParseTime(v); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Microsoft OAuth device-code flow through Xbox Live and XSTS to a
+// Minecraft Services access token. See
+// https://wiki.vg/Microsoft_Authentication_Scheme for the exchange chain.
+const (
+	microsoftDeviceCodeURL   = "https://login.microsoftonline.com/consumers/oauth2/v2.0/devicecode"
+	microsoftTokenURL        = "https://login.microsoftonline.com/consumers/oauth2/v2.0/token"
+	microsoftDeviceCodeScope = "XboxLive.signin offline_access"
+
+	xboxLiveAuthURL     = "https://user.auth.xboxlive.com/user/authenticate"
+	xstsAuthorizeURL    = "https://xsts.auth.xboxlive.com/xsts/authorize"
+	minecraftLoginURL   = "https://api.minecraftservices.com/authentication/login_with_xbox"
+	minecraftProfileURL = "https://api.minecraftservices.com/minecraft/profile"
+)
+
+// ErrAuthorizationPending is returned by MicrosoftAuthService.Poll while the
+// user hasn't finished signing in at the verification URL yet; callers
+// should poll again after DeviceCodeResponse.Interval seconds.
+var ErrAuthorizationPending = errors.New("authorization pending")
+
+// XboxAccountError reports an XSTS authorization failure tied to the
+// Xbox/Microsoft account itself (as opposed to a transient upstream error),
+// e.g. the account has no Xbox profile or is a child account that needs
+// adding to a family group.
+type XboxAccountError struct {
+	XErr    int64
+	Message string
+}
+
+func (e *XboxAccountError) Error() string { return e.Message }
+
+func classifyXSTSError(xerr int64) string {
+	switch xerr {
+	case 2148916233:
+		return "this Microsoft account has no Xbox Live profile; create one at https://www.xbox.com/live"
+	case 2148916238:
+		return "this Microsoft account belongs to a child and must be added to a Family group first"
+	default:
+		return fmt.Sprintf("XSTS authorization failed (XErr %d)", xerr)
+	}
+}
+
+type DeviceCodeResponse struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+	Message         string `json:"message"`
+}
+
+// MinecraftSession is the outcome of a completed device-code login: a
+// Minecraft Services access token usable against the game's session APIs.
+type MinecraftSession struct {
+	AccessToken       string
+	ExpiresAt         time.Time
+	MinecraftUUID     string
+	MinecraftUsername string
+}
+
+type storedMicrosoftToken struct {
+	RefreshToken string    `json:"refresh_token"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// MicrosoftAuthService drives the Microsoft OAuth device-code flow and
+// persists the refresh token issued for each caller (keyed by the name of
+// the instance whose "microsoft" auth backend the player signed in through).
+// The stored refresh token is not read back by this service: every login
+// goes through the device-code prompt.
+type MicrosoftAuthService struct {
+	clientID string
+	path     string
+
+	mu     sync.Mutex
+	tokens map[string]storedMicrosoftToken
+}
+
+func NewMicrosoftAuthService(clientID, path string) (*MicrosoftAuthService, error) {
+	s := &MicrosoftAuthService{clientID: clientID, path: path, tokens: map[string]storedMicrosoftToken{}}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Configured reports whether MICROSOFT_CLIENT_ID was set at startup. An
+// instance's auth backend can only be set to "microsoft" if so.
+func (s *MicrosoftAuthService) Configured() bool {
+	return s != nil && s.clientID != ""
+}
+
+func (s *MicrosoftAuthService) load() error {
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read microsoft tokens: %w", err)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, &s.tokens)
+}
+
+func (s *MicrosoftAuthService) persistLocked() error {
+	raw, err := json.MarshalIndent(s.tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode microsoft tokens: %w", err)
+	}
+	return os.WriteFile(s.path, raw, 0o600)
+}
+
+// RequestDeviceCode starts a new device-code flow, returning the code the
+// caller should display to the user along with the URL to sign in at.
+func (s *MicrosoftAuthService) RequestDeviceCode(ctx context.Context) (*DeviceCodeResponse, error) {
+	if s.clientID == "" {
+		return nil, errors.New("MICROSOFT_CLIENT_ID is not configured")
+	}
+	form := url.Values{
+		"client_id": {s.clientID},
+		"scope":     {microsoftDeviceCodeScope},
+	}
+	var resp DeviceCodeResponse
+	if err := postForm(ctx, microsoftDeviceCodeURL, form, &resp); err != nil {
+		return nil, fmt.Errorf("request device code: %w", err)
+	}
+	return &resp, nil
+}
+
+// Poll makes one attempt to redeem deviceCode for a Minecraft session. It
+// returns ErrAuthorizationPending while the user hasn't completed sign-in
+// yet; the caller should call Poll again after the interval RequestDeviceCode
+// returned. On success, the Microsoft refresh token is persisted under
+// instanceName for future reference.
+func (s *MicrosoftAuthService) Poll(ctx context.Context, instanceName, deviceCode string) (*MinecraftSession, error) {
+	if s.clientID == "" {
+		return nil, errors.New("MICROSOFT_CLIENT_ID is not configured")
+	}
+
+	msAccessToken, refreshToken, err := s.pollToken(ctx, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.tokens[instanceName] = storedMicrosoftToken{RefreshToken: refreshToken, UpdatedAt: time.Now().UTC()}
+	err = s.persistLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("persist refresh token: %w", err)
+	}
+
+	return exchangeForMinecraftSession(ctx, msAccessToken)
+}
+
+func (s *MicrosoftAuthService) pollToken(ctx context.Context, deviceCode string) (accessToken, refreshToken string, err error) {
+	form := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"client_id":   {s.clientID},
+		"device_code": {deviceCode},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, microsoftTokenURL, bytes.NewReader([]byte(form.Encode())))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	var payload struct {
+		Error        string `json:"error"`
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", "", fmt.Errorf("decode token response: %w", err)
+	}
+	if payload.Error == "authorization_pending" || payload.Error == "slow_down" {
+		return "", "", ErrAuthorizationPending
+	}
+	if payload.Error != "" {
+		return "", "", fmt.Errorf("microsoft token error: %s", payload.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("microsoft token error: %s", resp.Status)
+	}
+	return payload.AccessToken, payload.RefreshToken, nil
+}
+
+func exchangeForMinecraftSession(ctx context.Context, msAccessToken string) (*MinecraftSession, error) {
+	xblToken, uhs, err := xboxLiveAuthenticate(ctx, msAccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("xbox live authenticate: %w", err)
+	}
+	xstsToken, err := xstsAuthorize(ctx, xblToken)
+	if err != nil {
+		return nil, err
+	}
+	return minecraftLoginWithXbox(ctx, uhs, xstsToken)
+}
+
+type xboxTokenResponse struct {
+	Token         string `json:"Token"`
+	DisplayClaims struct {
+		Xui []struct {
+			Uhs string `json:"uhs"`
+		} `json:"xui"`
+	} `json:"DisplayClaims"`
+}
+
+func (r *xboxTokenResponse) userHash() (string, error) {
+	if len(r.DisplayClaims.Xui) == 0 || r.DisplayClaims.Xui[0].Uhs == "" {
+		return "", errors.New("xbox live response missing user hash")
+	}
+	return r.DisplayClaims.Xui[0].Uhs, nil
+}
+
+func xboxLiveAuthenticate(ctx context.Context, msAccessToken string) (token, uhs string, err error) {
+	body := map[string]interface{}{
+		"Properties": map[string]string{
+			"AuthMethod": "RPS",
+			"SiteName":   "user.auth.xboxlive.com",
+			"RpsTicket":  "d=" + msAccessToken,
+		},
+		"RelyingParty": "http://auth.xboxlive.com",
+		"TokenType":    "JWT",
+	}
+	var resp xboxTokenResponse
+	if err := postJSON(ctx, xboxLiveAuthURL, body, &resp); err != nil {
+		return "", "", err
+	}
+	uhs, err = resp.userHash()
+	if err != nil {
+		return "", "", err
+	}
+	return resp.Token, uhs, nil
+}
+
+// xstsAuthorize exchanges an XBL token for an XSTS token scoped to the
+// Minecraft Services relying party. Unlike the other exchange steps, a 401
+// here carries a structured XErr code identifying an account-level problem
+// (no Xbox profile, child account, banned, etc.), which is surfaced as an
+// *XboxAccountError rather than a generic upstream error.
+func xstsAuthorize(ctx context.Context, xblToken string) (string, error) {
+	body := map[string]interface{}{
+		"Properties": map[string]interface{}{
+			"SandboxId":  "RETAIL",
+			"UserTokens": []string{xblToken},
+		},
+		"RelyingParty": "rp://api.minecraftservices.com/",
+		"TokenType":    "JWT",
+	}
+	req, err := newJSONRequest(ctx, xstsAuthorizeURL, body)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		var xerr struct {
+			XErr int64 `json:"XErr"`
+		}
+		if json.Unmarshal(raw, &xerr) == nil && xerr.XErr != 0 {
+			return "", &XboxAccountError{XErr: xerr.XErr, Message: classifyXSTSError(xerr.XErr)}
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("xsts authorize error: %s", resp.Status)
+	}
+
+	var token xboxTokenResponse
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return "", fmt.Errorf("decode xsts response: %w", err)
+	}
+	return token.Token, nil
+}
+
+// minecraftLoginWithXbox redeems an XSTS token for a Minecraft Services
+// access token, then looks up the signed-in player's profile to learn their
+// actual Minecraft UUID and username: login_with_xbox's own "username" field
+// is the Xbox Live gamertag, not the Minecraft identity, so it can't be used
+// to populate MinecraftSession directly.
+func minecraftLoginWithXbox(ctx context.Context, uhs, xstsToken string) (*MinecraftSession, error) {
+	body := map[string]string{
+		"identityToken": fmt.Sprintf("XBL3.0 x=%s;%s", uhs, xstsToken),
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := postJSON(ctx, minecraftLoginURL, body, &resp); err != nil {
+		return nil, fmt.Errorf("minecraft login with xbox: %w", err)
+	}
+
+	uuid, username, err := fetchMinecraftProfile(ctx, resp.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MinecraftSession{
+		AccessToken:       resp.AccessToken,
+		ExpiresAt:         time.Now().UTC().Add(time.Duration(resp.ExpiresIn) * time.Second),
+		MinecraftUUID:     uuid,
+		MinecraftUsername: username,
+	}, nil
+}
+
+// fetchMinecraftProfile looks up the Minecraft profile (UUID and username)
+// owned by the account a Minecraft Services access token was issued to.
+func fetchMinecraftProfile(ctx context.Context, accessToken string) (uuid, username string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, minecraftProfileURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("fetch minecraft profile: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return "", "", fmt.Errorf("fetch minecraft profile: %s: %s", resp.Status, string(raw))
+	}
+
+	var profile struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return "", "", fmt.Errorf("decode minecraft profile: %w", err)
+	}
+	return profile.ID, profile.Name, nil
+}
+
+func newJSONRequest(ctx context.Context, endpoint string, body interface{}) (*http.Request, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+func postJSON(ctx context.Context, endpoint string, body, out interface{}) error {
+	req, err := newJSONRequest(ctx, endpoint, body)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, string(raw))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func postForm(ctx context.Context, endpoint string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader([]byte(form.Encode())))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, string(raw))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// OIDCService discovers and caches OIDC providers by issuer URL, so that
+// an instance's builder-config validation and its oidc-config descriptor
+// endpoint can share the same cached discovery document and JWKS instead of
+// re-fetching .well-known/openid-configuration on every request.
+type OIDCService struct {
+	mu        sync.Mutex
+	providers map[string]*oidc.Provider
+}
+
+func NewOIDCService() *OIDCService {
+	return &OIDCService{providers: make(map[string]*oidc.Provider)}
+}
+
+// Discover returns the cached provider for issuer, performing OIDC discovery
+// the first time issuer is seen.
+func (s *OIDCService) Discover(ctx context.Context, issuer string) (*oidc.Provider, error) {
+	s.mu.Lock()
+	if p, ok := s.providers[issuer]; ok {
+		s.mu.Unlock()
+		return p, nil
+	}
+	s.mu.Unlock()
+
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("discover OIDC issuer %q: %w", issuer, err)
+	}
+
+	s.mu.Lock()
+	s.providers[issuer] = provider
+	s.mu.Unlock()
+	return provider, nil
+}
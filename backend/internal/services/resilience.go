@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/metrics"
+)
+
+const (
+	breakerFailureThreshold = 5
+	breakerCooldown         = 30 * time.Second
+)
+
+// upstreamCacheTTL is how long a successful upstream fetch is served without
+// revalidation. It defaults to 5 minutes but is overridden from config at
+// startup via SetUpstreamCacheTTL.
+var upstreamCacheTTL = 5 * time.Minute
+
+// SetUpstreamCacheTTL configures how long GetVanillaVersions/GetLoadersForVersion/
+// GetLoaderVersions serve a cached upstream response before revalidating.
+func SetUpstreamCacheTTL(ttl time.Duration) {
+	if ttl > 0 {
+		upstreamCacheTTL = ttl
+	}
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrBreakerOpen is returned (wrapped) when a circuit breaker is open and
+// the call wasn't even attempted.
+var ErrBreakerOpen = errors.New("circuit breaker open")
+
+// CircuitBreaker guards one upstream dependency: it trips to open after
+// breakerFailureThreshold consecutive failures, then after breakerCooldown
+// allows exactly one half-open probe to decide whether to close again.
+type CircuitBreaker struct {
+	name string
+
+	mu            sync.Mutex
+	state         breakerState
+	consecutive   int
+	openedAt      time.Time
+	halfOpenProbe bool
+}
+
+func newCircuitBreaker(name string) *CircuitBreaker {
+	return &CircuitBreaker{name: name}
+}
+
+// State reports the breaker's current state for the upstreams health
+// endpoint.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.resolvedLocked().String()
+}
+
+// resolvedLocked reports breakerOpen as breakerHalfOpen once the cooldown
+// has elapsed, without mutating b.state: the transition only commits once
+// the probe call actually resolves, via recordSuccess/recordFailure.
+func (b *CircuitBreaker) resolvedLocked() breakerState {
+	if b.state == breakerOpen && time.Since(b.openedAt) >= breakerCooldown {
+		return breakerHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether a call should be attempted now, claiming the single
+// half-open probe slot if the breaker just became eligible for one.
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.resolvedLocked() {
+	case breakerOpen:
+		return false
+	case breakerHalfOpen:
+		if b.halfOpenProbe {
+			return false
+		}
+		b.halfOpenProbe = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *CircuitBreaker) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = breakerClosed
+	b.consecutive = 0
+	b.halfOpenProbe = false
+}
+
+func (b *CircuitBreaker) recordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpenProbe = false
+	if b.resolvedLocked() == breakerHalfOpen {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		return
+	}
+	b.consecutive++
+	if b.consecutive >= breakerFailureThreshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// upstreamError carries enough of an upstream HTTP response to decide
+// whether backoff.Retry should retry it, and for how long to wait first.
+type upstreamError struct {
+	statusCode int
+	retryAfter time.Duration
+	err        error
+}
+
+func (e *upstreamError) Error() string { return e.err.Error() }
+func (e *upstreamError) Unwrap() error { return e.err }
+
+// retryable reports whether the status code is worth retrying: network
+// errors and 5xx/429 are transient, other 4xx are not.
+func (e *upstreamError) retryable() bool {
+	return e.statusCode == 0 || e.statusCode == 429 || e.statusCode >= 500
+}
+
+// callUpstream runs fetch with exponential backoff (InitialInterval 200ms,
+// MaxInterval 5s, MaxElapsedTime 15s), retrying transient failures and
+// honoring a 429's Retry-After, behind breaker so a consistently failing
+// upstream stops being hammered once it trips open.
+func callUpstream[T any](ctx context.Context, breaker *CircuitBreaker, fetch func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	if !breaker.allow() {
+		metrics.UpstreamFetchTotal.WithLabelValues(breaker.name, "breaker_open").Inc()
+		return zero, fmt.Errorf("%s: %w", breaker.name, ErrBreakerOpen)
+	}
+	start := time.Now()
+
+	bo := backoff.NewExponentialBackOff()
+	bo.InitialInterval = 200 * time.Millisecond
+	bo.MaxInterval = 5 * time.Second
+	bo.MaxElapsedTime = 15 * time.Second
+
+	result, err := backoff.RetryWithData(func() (T, error) {
+		v, err := fetch(ctx)
+		if err == nil {
+			return v, nil
+		}
+
+		var upErr *upstreamError
+		if errors.As(err, &upErr) {
+			if upErr.retryAfter > 0 {
+				// Cap the honored Retry-After to whatever's left of
+				// MaxElapsedTime, so an upstream-supplied value (Mojang/Forge
+				// have been seen returning multi-minute ones) can't make a
+				// single call block far past the documented 15s bound.
+				remaining := bo.MaxElapsedTime - time.Since(start)
+				if remaining <= 0 {
+					return zero, backoff.Permanent(err)
+				}
+				wait := upErr.retryAfter
+				if wait > remaining {
+					wait = remaining
+				}
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return zero, backoff.Permanent(ctx.Err())
+				}
+			}
+			if !upErr.retryable() {
+				return zero, backoff.Permanent(err)
+			}
+		}
+		return zero, err
+	}, backoff.WithContext(bo, ctx))
+
+	metrics.UpstreamFetchDuration.WithLabelValues(breaker.name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		breaker.recordFailure()
+		metrics.UpstreamFetchTotal.WithLabelValues(breaker.name, "failure").Inc()
+		return zero, err
+	}
+	breaker.recordSuccess()
+	metrics.UpstreamFetchTotal.WithLabelValues(breaker.name, "success").Inc()
+	return result, nil
+}
+
+// staleCacheEntry is one cached upstream response.
+type staleCacheEntry[T any] struct {
+	value     T
+	updatedAt time.Time
+}
+
+// staleCache is a stale-while-revalidate cache: Get returns the cached value
+// while it's within TTL without touching the upstream at all, and Stale lets
+// a caller fall back to the last-known-good value once upstream fails.
+type staleCache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]staleCacheEntry[T]
+}
+
+func newStaleCache[T any]() *staleCache[T] {
+	return &staleCache[T]{entries: make(map[string]staleCacheEntry[T])}
+}
+
+func (c *staleCache[T]) fresh(key string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.updatedAt) > upstreamCacheTTL {
+		var zero T
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (c *staleCache[T]) stale(key string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (c *staleCache[T]) set(key string, v T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = staleCacheEntry[T]{value: v, updatedAt: time.Now()}
+}
+
+// fetchResilient serves key from cache if fresh, otherwise calls upstream
+// (retried, behind breaker); if that fails it falls back to the last cached
+// value for key and reports it as stale, so the caller can still return
+// something instead of a 503.
+func fetchResilient[T any](ctx context.Context, breaker *CircuitBreaker, cache *staleCache[T], key string, fetch func(ctx context.Context) (T, error)) (value T, stale bool, err error) {
+	if v, ok := cache.fresh(key); ok {
+		return v, false, nil
+	}
+
+	v, err := callUpstream(ctx, breaker, fetch)
+	if err != nil {
+		if last, ok := cache.stale(key); ok {
+			return last, true, nil
+		}
+		var zero T
+		return zero, false, err
+	}
+	cache.set(key, v)
+	return v, false, nil
+}
+
+// UpstreamBreakerStates reports the current state of every upstream circuit
+// breaker, for a health endpoint.
+func UpstreamBreakerStates() map[string]string {
+	return map[string]string{
+		"mojang":   mojangBreaker.State(),
+		"fabric":   fabricBreaker.State(),
+		"quilt":    quiltBreaker.State(),
+		"forge":    forgeBreaker.State(),
+		"neoforge": neoforgeBreaker.State(),
+	}
+}
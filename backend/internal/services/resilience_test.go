@@ -0,0 +1,100 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// openBreaker drives b through breakerFailureThreshold consecutive failures
+// so it trips open, the way callUpstream would after that many failed
+// fetches in a row.
+func openBreaker(b *CircuitBreaker) {
+	for i := 0; i < breakerFailureThreshold; i++ {
+		b.allow()
+		b.recordFailure()
+	}
+}
+
+// expireCooldown backdates openedAt past breakerCooldown, standing in for
+// time.Sleep(breakerCooldown) so the half-open transition can be tested
+// without actually waiting.
+func expireCooldown(b *CircuitBreaker) {
+	b.mu.Lock()
+	b.openedAt = time.Now().Add(-breakerCooldown - time.Millisecond)
+	b.mu.Unlock()
+}
+
+func TestCircuitBreakerTripsAfterThreshold(t *testing.T) {
+	b := newCircuitBreaker("test")
+
+	for i := 0; i < breakerFailureThreshold-1; i++ {
+		if !b.allow() {
+			t.Fatalf("call %d: expected closed breaker to allow", i)
+		}
+		b.recordFailure()
+		if got := b.State(); got != "closed" {
+			t.Fatalf("call %d: state = %q, want closed", i, got)
+		}
+	}
+
+	if !b.allow() {
+		t.Fatal("expected the threshold-th call to still be allowed")
+	}
+	b.recordFailure()
+
+	if got := b.State(); got != "open" {
+		t.Fatalf("state = %q, want open after %d consecutive failures", got, breakerFailureThreshold)
+	}
+	if b.allow() {
+		t.Fatal("expected an open breaker to reject calls")
+	}
+}
+
+func TestCircuitBreakerHalfOpenAllowsOneProbe(t *testing.T) {
+	b := newCircuitBreaker("test")
+	openBreaker(b)
+	expireCooldown(b)
+
+	if got := b.State(); got != "half_open" {
+		t.Fatalf("state = %q, want half_open once cooldown has elapsed", got)
+	}
+	if !b.allow() {
+		t.Fatal("expected the first half-open call to be allowed as the probe")
+	}
+	if b.allow() {
+		t.Fatal("expected a second concurrent half-open call to be rejected")
+	}
+}
+
+func TestCircuitBreakerClosesOnProbeSuccess(t *testing.T) {
+	b := newCircuitBreaker("test")
+	openBreaker(b)
+	expireCooldown(b)
+
+	if !b.allow() {
+		t.Fatal("expected the probe call to be allowed")
+	}
+	b.recordSuccess()
+
+	if got := b.State(); got != "closed" {
+		t.Fatalf("state = %q, want closed after a successful probe", got)
+	}
+	if !b.allow() {
+		t.Fatal("expected a closed breaker to allow calls")
+	}
+}
+
+func TestCircuitBreakerReopensOnProbeFailure(t *testing.T) {
+	b := newCircuitBreaker("test")
+	openBreaker(b)
+	expireCooldown(b)
+
+	if !b.allow() {
+		t.Fatal("expected the probe call to be allowed")
+	}
+	b.recordFailure()
+
+	if got := b.State(); got != "open" {
+		t.Fatalf("state = %q, want open again after the probe itself failed", got)
+	}
+}
@@ -9,19 +9,26 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Petr1Furious/potato-launcher/backend/internal/config"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/metrics"
 	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/storage"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/upload"
 )
 
 type SpecProvider interface {
-	GetSpec() (*models.Spec, error)
+	GetSpec() (*models.BuilderSpec, error)
 }
 
 type RunnerService struct {
 	cfg     *config.Config
 	store   SpecProvider
+	backend storage.Backend
 	status  models.BuildStatus
 	mu      sync.RWMutex
 	running bool
@@ -29,13 +36,14 @@ type RunnerService struct {
 	hub     *Hub
 }
 
-func NewRunnerService(cfg *config.Config, store SpecProvider, logger *slog.Logger, hub *Hub) *RunnerService {
+func NewRunnerService(cfg *config.Config, store SpecProvider, backend storage.Backend, logger *slog.Logger, hub *Hub) *RunnerService {
 	return &RunnerService{
-		cfg:    cfg,
-		store:  store,
-		status: models.BuildIdle,
-		logger: logger,
-		hub:    hub,
+		cfg:     cfg,
+		store:   store,
+		backend: backend,
+		status:  models.BuildIdle,
+		logger:  logger,
+		hub:     hub,
 	}
 }
 
@@ -45,7 +53,13 @@ func (r *RunnerService) Status() models.BuildStatus {
 	return r.status
 }
 
-func (r *RunnerService) RunBuild(ctx context.Context) error {
+// RunInstances runs the instance_builder synchronously, optionally limited
+// to the given instance names (nil/empty means every instance), and returns
+// once the build process has exited. Only one build may run at a time,
+// regardless of caller, since the builder shares GeneratedDir/WorkdirDir. If
+// out is non-nil, every stdout/stderr line is also written to it (one Write
+// call per line, no trailing newline).
+func (r *RunnerService) RunInstances(ctx context.Context, names []string, out io.Writer) error {
 	r.mu.Lock()
 	if r.running {
 		r.mu.Unlock()
@@ -55,18 +69,38 @@ func (r *RunnerService) RunBuild(ctx context.Context) error {
 	r.status = models.BuildRunning
 	r.mu.Unlock()
 
-	go r.execute(context.Background())
-	return nil
-}
+	spec, specErr := r.store.GetSpec()
+	var targets []models.BuilderInstance
+	if specErr == nil {
+		targets = filterInstances(spec.Instances, names)
+	}
+	for _, instance := range targets {
+		metrics.BuildStatus.WithLabelValues(instance.Name).Set(1)
+	}
+	start := time.Now()
+
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.status = models.BuildIdle
+		r.mu.Unlock()
+
+		elapsed := time.Since(start).Seconds()
+		for _, instance := range targets {
+			metrics.BuildDuration.WithLabelValues(instance.Name, string(instance.LoaderName)).Observe(elapsed)
+			metrics.BuildStatus.WithLabelValues(instance.Name).Set(0)
+		}
+	}()
 
-func (r *RunnerService) execute(ctx context.Context) {
-	r.logger.Info("starting build process")
+	r.logger.Info("starting build process", "instances", names)
 	r.broadcastLog("Starting build process...")
 
-	if err := r.prepareSpecFile(); err != nil {
-		r.finish(err)
-		return
+	cleanup, err := r.prepareSpecFile(ctx, names)
+	if err != nil {
+		r.logOutcome(err)
+		return err
 	}
+	defer cleanup()
 
 	cmd := exec.CommandContext(
 		ctx,
@@ -81,32 +115,36 @@ func (r *RunnerService) execute(ctx context.Context) {
 	stderr, _ := cmd.StderrPipe()
 
 	if err := cmd.Start(); err != nil {
-		r.finish(err)
-		return
+		r.logOutcome(err)
+		return err
 	}
 
 	var wg sync.WaitGroup
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		r.streamLog(stdout)
+		r.streamLog(stdout, out)
 	}()
 	go func() {
 		defer wg.Done()
-		r.streamLog(stderr)
+		r.streamLog(stderr, out)
 	}()
 
-	err := cmd.Wait()
+	err = cmd.Wait()
 	wg.Wait()
-	r.finish(err)
+	r.logOutcome(err)
+	return err
 }
 
-func (r *RunnerService) streamLog(pipe io.ReadCloser) {
+func (r *RunnerService) streamLog(pipe io.ReadCloser, out io.Writer) {
 	scanner := bufio.NewScanner(pipe)
 	for scanner.Scan() {
 		text := scanner.Text()
 		r.logger.Debug("build log", "line", text)
 		r.broadcastLog(text)
+		if out != nil {
+			out.Write([]byte(text))
+		}
 	}
 }
 
@@ -117,12 +155,7 @@ func (r *RunnerService) broadcastLog(text string) {
 	})
 }
 
-func (r *RunnerService) finish(runErr error) {
-	r.mu.Lock()
-	r.running = false
-	r.status = models.BuildIdle
-	r.mu.Unlock()
-
+func (r *RunnerService) logOutcome(runErr error) {
 	if runErr != nil {
 		r.logger.Error("runner failed", "error", runErr)
 		r.broadcastLog(fmt.Sprintf("Build failed: %v", runErr))
@@ -132,10 +165,34 @@ func (r *RunnerService) finish(runErr error) {
 	}
 }
 
-func (r *RunnerService) prepareSpecFile() error {
+// prepareSpecFile writes the spec the instance_builder binary understands.
+// When names is non-empty, only those instances are included so the builder
+// rebuilds a single modpack instead of everything. Instances whose
+// IncludeFrom points at a remote object-storage backend (e.g. "s3://...")
+// are staged to a local temp checkout first, since the builder only
+// understands filesystem paths; the returned cleanup func removes that
+// staging directory once the build finishes.
+func (r *RunnerService) prepareSpecFile(ctx context.Context, names []string) (func(), error) {
 	spec, err := r.store.GetSpec()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	instances := filterInstances(spec.Instances, names)
+
+	cleanup := func() {}
+	for i, instance := range instances {
+		if !isRemoteIncludeFrom(instance.IncludeFrom) {
+			continue
+		}
+		localDir, instanceCleanup, err := r.stageInclude(ctx, instance.Name)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("stage includes for %s: %w", instance.Name, err)
+		}
+		prev := cleanup
+		cleanup = func() { prev(); instanceCleanup() }
+		instances[i].IncludeFrom = localDir
 	}
 
 	builderSpec := models.BuilderSpec{
@@ -144,11 +201,91 @@ func (r *RunnerService) prepareSpecFile() error {
 		ReplaceDownloadURLs: spec.ReplaceDownloadURLs,
 		ExecBeforeAll:       r.cfg.ExecBeforeAll,
 		ExecAfterAll:        r.cfg.ExecAfterAll,
-		Versions:            spec.Versions,
+		Instances:           instances,
 	}
 	raw, err := json.MarshalIndent(builderSpec, "", "    ")
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := os.WriteFile(r.cfg.SpecFile, raw, 0o644); err != nil {
+		cleanup()
+		return nil, err
+	}
+	return cleanup, nil
+}
+
+// filterInstances returns the subset of instances named in names, preserving
+// spec order. An empty/nil names selects every instance.
+func filterInstances(instances []models.BuilderInstance, names []string) []models.BuilderInstance {
+	if len(names) == 0 {
+		out := make([]models.BuilderInstance, len(instances))
+		copy(out, instances)
+		return out
+	}
+
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+	out := make([]models.BuilderInstance, 0, len(names))
+	for _, instance := range instances {
+		if _, ok := wanted[instance.Name]; ok {
+			out = append(out, instance)
+		}
+	}
+	return out
+}
+
+func isRemoteIncludeFrom(includeFrom string) bool {
+	return strings.Contains(includeFrom, "://") && !strings.HasPrefix(includeFrom, "file://")
+}
+
+// stageInclude reconstructs an instance's uploaded include tree into a
+// fresh temp directory from its content-addressed manifest, and returns the
+// directory's path along with a cleanup func.
+func (r *RunnerService) stageInclude(ctx context.Context, instanceName string) (string, func(), error) {
+	dir, err := os.MkdirTemp(r.cfg.TempDir, fmt.Sprintf("instance-%s-", instanceName))
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	prefix := instanceName + "/"
+	manifest, err := upload.LoadManifest(ctx, r.backend, prefix+"manifest.json")
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	for rel, entry := range manifest.Files {
+		objectKey := prefix + upload.ObjectKey(entry.SHA256)
+		if err := r.stageObject(ctx, objectKey, os.FileMode(entry.Mode), dir, rel); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+	return dir, cleanup, nil
+}
+
+func (r *RunnerService) stageObject(ctx context.Context, key string, mode os.FileMode, dir, rel string) error {
+	rc, _, err := r.backend.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	dst := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if mode == 0 {
+		mode = 0o644
+	}
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(r.cfg.SpecFile, raw, 0o644)
+	defer f.Close()
+	_, err = io.Copy(f, rc)
+	return err
 }
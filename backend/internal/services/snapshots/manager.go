@@ -0,0 +1,411 @@
+// Package snapshots lets an operator back up spec.json (and, optionally,
+// every instance's uploaded files) before a risky edit or build, and
+// restore from one of those backups if it goes wrong.
+package snapshots
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/models"
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/storage"
+)
+
+const specEntryName = "spec.json"
+
+// Snapshot is a single timestamped backup of spec.json, and optionally of
+// every instance's include directory, stored as "<id>.tar.gz" under Dir.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Automatic bool      `json:"automatic"`
+	WithDirs  bool      `json:"with_dirs"`
+}
+
+// SpecStore is the subset of store.Store a Manager needs: reading the
+// current spec to snapshot it, and atomically replacing it on restore.
+type SpecStore interface {
+	GetSpec() (*models.BuilderSpec, error)
+	Replace(spec *models.BuilderSpec) error
+}
+
+// Manager creates, lists, restores and prunes snapshots. Its manifest is a
+// JSON file next to the tarballs, following the same
+// read-whole-file/write-whole-file pattern as store.Store and jobs.Manager.
+type Manager struct {
+	mu           sync.Mutex
+	dir          string
+	manifestPath string
+	keepAuto     int
+
+	store   SpecStore
+	backend storage.Backend
+}
+
+func NewManager(dir string, store SpecStore, backend storage.Backend, keepAutomatic int) (*Manager, error) {
+	if dir == "" {
+		return nil, errors.New("snapshots dir is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create snapshots dir: %w", err)
+	}
+	return &Manager{
+		dir:          dir,
+		manifestPath: filepath.Join(dir, "manifest.json"),
+		keepAuto:     keepAutomatic,
+		store:        store,
+		backend:      backend,
+	}, nil
+}
+
+func (m *Manager) tarPath(id string) string {
+	return filepath.Join(m.dir, id+".tar.gz")
+}
+
+func (m *Manager) loadManifestLocked() ([]*Snapshot, error) {
+	raw, err := os.ReadFile(m.manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot manifest: %w", err)
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var list []*Snapshot
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("decode snapshot manifest: %w", err)
+	}
+	return list, nil
+}
+
+func (m *Manager) saveManifestLocked(list []*Snapshot) error {
+	raw, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode snapshot manifest: %w", err)
+	}
+	if err := os.WriteFile(m.manifestPath, raw, 0o644); err != nil {
+		return fmt.Errorf("write snapshot manifest: %w", err)
+	}
+	return nil
+}
+
+// Create snapshots the current spec and, if withDirs is set, every
+// instance's uploaded files, into a new tar.gz. automatic marks it as one of
+// the pre-build backups subject to retention pruning.
+func (m *Manager) Create(ctx context.Context, automatic, withDirs bool) (*Snapshot, error) {
+	spec, err := m.store.GetSpec()
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.NewString()
+	path := m.tarPath(id)
+	if err := m.writeTarball(ctx, path, spec, withDirs); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	size, sum, err := hashFile(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		ID:        id,
+		CreatedAt: time.Now(),
+		Size:      size,
+		SHA256:    sum,
+		Automatic: automatic,
+		WithDirs:  withDirs,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.loadManifestLocked()
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	list = append(list, snap)
+	if automatic {
+		list = pruneAutomatic(list, m.keepAuto, func(id string) { os.Remove(m.tarPath(id)) })
+	}
+	if err := m.saveManifestLocked(list); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return snap, nil
+}
+
+// pruneAutomatic drops the oldest automatic snapshots beyond keep, removing
+// their tarballs via remove, and returns the surviving list.
+func pruneAutomatic(list []*Snapshot, keep int, remove func(id string)) []*Snapshot {
+	if keep <= 0 {
+		return list
+	}
+	autoCount := 0
+	for i := len(list) - 1; i >= 0; i-- {
+		if !list[i].Automatic {
+			continue
+		}
+		autoCount++
+		if autoCount > keep {
+			remove(list[i].ID)
+			list = append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
+func (m *Manager) writeTarball(ctx context.Context, path string, spec *models.BuilderSpec, withDirs bool) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	raw, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarEntry(tw, specEntryName, raw, 0o644); err != nil {
+		return err
+	}
+
+	if withDirs {
+		for _, instance := range spec.Instances {
+			if err := m.addInstanceDir(ctx, tw, instance.Name); err != nil {
+				return fmt.Errorf("snapshot instance %s: %w", instance.Name, err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func (m *Manager) addInstanceDir(ctx context.Context, tw *tar.Writer, instanceName string) error {
+	prefix := instanceName + "/"
+	objects, err := m.backend.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		if rel == "" {
+			continue
+		}
+		rc, info, err := m.backend.Get(ctx, obj.Key)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		if err := writeTarEntry(tw, filepath.ToSlash(filepath.Join("instances", instanceName, rel)), data, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte, mode os.FileMode) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Size:    int64(len(data)),
+		Mode:    int64(mode.Perm()),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// List returns every snapshot, newest first.
+func (m *Manager) List() ([]*Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.loadManifestLocked()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// Get returns a single snapshot's metadata.
+func (m *Manager) Get(id string) (*Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.loadManifestLocked()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range list {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot not found")
+}
+
+// Delete removes a snapshot's tarball and drops it from the manifest.
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.loadManifestLocked()
+	if err != nil {
+		return err
+	}
+	idx := -1
+	for i, s := range list {
+		if s.ID == id {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return fmt.Errorf("snapshot not found")
+	}
+	list = append(list[:idx], list[idx+1:]...)
+	if err := m.saveManifestLocked(list); err != nil {
+		return err
+	}
+	return os.Remove(m.tarPath(id))
+}
+
+// Restore replaces the live spec with the one captured in snapshot id, and,
+// if the snapshot has them and restoreDirs is set, re-materializes every
+// instance's uploaded files into the storage backend.
+func (m *Manager) Restore(ctx context.Context, id string, restoreDirs bool) error {
+	f, err := os.Open(m.tarPath(id))
+	if err != nil {
+		return fmt.Errorf("open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("open snapshot: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	var spec *models.BuilderSpec
+	type instanceFile struct {
+		instance string
+		rel      string
+		mode     os.FileMode
+		data     []byte
+	}
+	var instanceFiles []instanceFile
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read snapshot: %w", err)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("read snapshot entry %s: %w", hdr.Name, err)
+		}
+
+		switch {
+		case hdr.Name == specEntryName:
+			var s models.BuilderSpec
+			if err := json.Unmarshal(data, &s); err != nil {
+				return fmt.Errorf("decode snapshot spec: %w", err)
+			}
+			spec = &s
+		case strings.HasPrefix(hdr.Name, "instances/"):
+			parts := strings.SplitN(strings.TrimPrefix(hdr.Name, "instances/"), "/", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			instanceFiles = append(instanceFiles, instanceFile{
+				instance: parts[0],
+				rel:      parts[1],
+				mode:     os.FileMode(hdr.Mode),
+				data:     data,
+			})
+		}
+	}
+
+	if spec == nil {
+		return fmt.Errorf("snapshot is missing %s", specEntryName)
+	}
+	if err := m.store.Replace(spec); err != nil {
+		return fmt.Errorf("restore spec: %w", err)
+	}
+
+	if !restoreDirs {
+		return nil
+	}
+	for _, file := range instanceFiles {
+		key := file.instance + "/" + file.rel
+		mode := file.mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		if err := m.backend.Put(ctx, key, bytes.NewReader(file.data), int64(len(file.data)), mode); err != nil {
+			return fmt.Errorf("restore %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package storage abstracts where uploaded instance files and launcher
+// artifacts live, so the backend can run against a local volume or an
+// S3-compatible object store without the rest of the codebase caring which
+// one is in play.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrPresignNotSupported is returned by PresignedURL when the backend has no
+// notion of presigned links (e.g. the local filesystem).
+var ErrPresignNotSupported = errors.New("storage: presigned URLs not supported by this backend")
+
+// ErrNotFound is returned (optionally wrapped) by Get and Stat when key
+// doesn't exist.
+var ErrNotFound = errors.New("storage: object not found")
+
+// SanitizeRelativePath cleans a caller-supplied path component and rejects
+// one that would escape its intended root via "..", mirroring the check
+// internal/api applies to uploaded filenames (see sanitizeRelativePath in
+// instances.go). Callers building a storage key out of an externally
+// supplied path component (e.g. a build agent's reported artifact path)
+// must sanitize it first; LocalBackend and S3Backend both reject unclean
+// keys independently as a second line of defense.
+func SanitizeRelativePath(name string) (string, error) {
+	clean := path.Clean(strings.TrimLeft(name, "/"))
+	if clean == "" || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("storage: invalid relative path: %s", name)
+	}
+	return clean, nil
+}
+
+// ObjectInfo describes a stored object, keyed by "<prefix>/<relpath>"
+// (instance uploads use "<instance>/<relpath>"; launcher artifacts use
+// "launchers/<os>/<artifact>/<relpath>").
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	Mode         os.FileMode
+	LastModified time.Time
+}
+
+// Backend stores and retrieves instance files and launcher artifacts,
+// addressed by a namespaced key such as "<instance>/<relpath>" or
+// "launchers/<os>/<artifact>/<relpath>". Implementations must be safe for
+// concurrent use.
+type Backend interface {
+	// Put writes size bytes from r to key.
+	Put(ctx context.Context, key string, r io.Reader, size int64, mode os.FileMode) error
+	// Get opens key for reading, or returns an error wrapping ErrNotFound if
+	// key doesn't exist. Callers must close the returned reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	// Stat returns metadata for key without reading its contents, or an
+	// error wrapping ErrNotFound if key doesn't exist.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// List returns every object whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Delete removes key. It is not an error if key does not exist.
+	Delete(ctx context.Context, key string) error
+	// PresignedURL returns a time-limited URL to fetch key directly, or
+	// ErrPresignNotSupported if the backend can't produce one.
+	PresignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// URI returns the canonical backend-URI for key (e.g. a local path or an
+	// "s3://bucket/key" URI) to be stored as BuilderInstance.IncludeFrom.
+	URI(key string) string
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalBackend stores objects as files under Root, rooted the same way
+// UploadedInstancesDir always has been.
+type LocalBackend struct {
+	Root string
+}
+
+func NewLocalBackend(root string) *LocalBackend {
+	return &LocalBackend{Root: root}
+}
+
+func (b *LocalBackend) path(key string) (string, error) {
+	abs, err := filepath.Abs(filepath.Join(b.Root, filepath.FromSlash(key)))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(b.Root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("storage: key escapes root: %s", key)
+	}
+	return abs, nil
+}
+
+func (b *LocalBackend) Put(ctx context.Context, key string, r io.Reader, size int64, mode os.FileMode) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (b *LocalBackend) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ObjectInfo{}, fmt.Errorf("storage: get %s: %w", key, ErrNotFound)
+		}
+		return nil, ObjectInfo{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, ObjectInfo{}, err
+	}
+	return f, fileInfoToObject(key, info), nil
+}
+
+func (b *LocalBackend) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ObjectInfo{}, fmt.Errorf("storage: stat %s: %w", key, ErrNotFound)
+		}
+		return ObjectInfo{}, err
+	}
+	return fileInfoToObject(key, info), nil
+}
+
+func (b *LocalBackend) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	root, err := b.path(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var out []ObjectInfo
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(b.Root, path)
+		if err != nil {
+			return err
+		}
+		out = append(out, fileInfoToObject(filepath.ToSlash(rel), info))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *LocalBackend) PresignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+func (b *LocalBackend) URI(key string) string {
+	path, err := b.path(key)
+	if err != nil {
+		return filepath.ToSlash(filepath.Join(b.Root, key))
+	}
+	return filepath.ToSlash(path)
+}
+
+func fileInfoToObject(key string, info os.FileInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          key,
+		Size:         info.Size(),
+		Mode:         info.Mode(),
+		LastModified: info.ModTime(),
+	}
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestLocalBackendPutGetStatDelete(t *testing.T) {
+	b := NewLocalBackend(t.TempDir())
+	ctx := t.Context()
+
+	content := []byte("hello world")
+	if err := b.Put(ctx, "instance/modpack.zip", bytes.NewReader(content), int64(len(content)), 0o644); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	info, err := b.Stat(ctx, "instance/modpack.zip")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != int64(len(content)) {
+		t.Fatalf("Stat size = %d, want %d", info.Size, len(content))
+	}
+
+	r, info, err := b.Get(ctx, "instance/modpack.zip")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("Get content = %q, want %q", got, content)
+	}
+	if info.Size != int64(len(content)) {
+		t.Fatalf("Get size = %d, want %d", info.Size, len(content))
+	}
+
+	if err := b.Delete(ctx, "instance/modpack.zip"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := b.Stat(ctx, "instance/modpack.zip"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat after delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalBackendList(t *testing.T) {
+	b := NewLocalBackend(t.TempDir())
+	ctx := t.Context()
+
+	for _, key := range []string{"instance/a.txt", "instance/sub/b.txt", "other/c.txt"} {
+		if err := b.Put(ctx, key, bytes.NewReader([]byte("x")), 1, 0o644); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	objs, err := b.List(ctx, "instance")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("List returned %d objects, want 2: %+v", len(objs), objs)
+	}
+}
+
+func TestLocalBackendRejectsPathTraversal(t *testing.T) {
+	b := NewLocalBackend(t.TempDir())
+	ctx := t.Context()
+
+	if err := b.Put(ctx, "../escape.txt", bytes.NewReader([]byte("x")), 1, 0o644); err == nil {
+		t.Fatal("expected Put with a traversing key to fail")
+	}
+	if _, _, err := b.Get(ctx, "../escape.txt"); err == nil {
+		t.Fatal("expected Get with a traversing key to fail")
+	}
+}
+
+func TestSanitizeRelativePath(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"simple file", "file.txt", false},
+		{"nested", "dir/file.txt", false},
+		{"leading slash stripped", "/dir/file.txt", false},
+		{"dot", ".", true},
+		{"empty", "", true},
+		{"parent escape", "..", true},
+		{"nested parent escape", "../secret", true},
+		{"cleaned still escapes", "a/../../secret", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := SanitizeRelativePath(tc.in)
+			if tc.wantErr && err == nil {
+				t.Fatalf("SanitizeRelativePath(%q): expected error, got nil", tc.in)
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("SanitizeRelativePath(%q): unexpected error: %v", tc.in, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Backend stores objects in a single bucket of an S3-compatible object
+// store (AWS S3, MinIO, ...), configured via STORAGE_* environment
+// variables (see config.Config).
+type S3Backend struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Backend(endpoint, bucket, accessKey, secretKey string, useSSL bool) (*S3Backend, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage: create minio client: %w", err)
+	}
+	return &S3Backend{client: client, bucket: bucket}, nil
+}
+
+func (b *S3Backend) Put(ctx context.Context, key string, r io.Reader, size int64, mode os.FileMode) error {
+	key, err := SanitizeRelativePath(key)
+	if err != nil {
+		return err
+	}
+	_, err = b.client.PutObject(ctx, b.bucket, key, r, size, minio.PutObjectOptions{
+		UserMetadata: map[string]string{"mode": fmt.Sprintf("%o", mode.Perm())},
+	})
+	if err != nil {
+		return fmt.Errorf("storage: put %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *S3Backend) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	key, err := SanitizeRelativePath(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	obj, err := b.client.GetObject(ctx, b.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("storage: get %s: %w", key, err)
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if isNotFound(err) {
+			return nil, ObjectInfo{}, fmt.Errorf("storage: get %s: %w", key, ErrNotFound)
+		}
+		return nil, ObjectInfo{}, fmt.Errorf("storage: stat %s: %w", key, err)
+	}
+	return obj, objectInfoFromMinio(key, info), nil
+}
+
+func (b *S3Backend) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	key, err := SanitizeRelativePath(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := b.client.StatObject(ctx, b.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return ObjectInfo{}, fmt.Errorf("storage: stat %s: %w", key, ErrNotFound)
+		}
+		return ObjectInfo{}, fmt.Errorf("storage: stat %s: %w", key, err)
+	}
+	return objectInfoFromMinio(key, info), nil
+}
+
+// isNotFound reports whether err is the MinIO error response for a missing
+// object or bucket.
+func isNotFound(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return resp.Code == "NoSuchKey" || resp.Code == "NoSuchBucket"
+}
+
+func (b *S3Backend) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var out []ObjectInfo
+	for obj := range b.client.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("storage: list %s: %w", prefix, obj.Err)
+		}
+		out = append(out, ObjectInfo{
+			Key:          obj.Key,
+			Size:         obj.Size,
+			Mode:         0o644,
+			LastModified: obj.LastModified,
+		})
+	}
+	return out, nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	key, err := SanitizeRelativePath(key)
+	if err != nil {
+		return err
+	}
+	if err := b.client.RemoveObject(ctx, b.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("storage: delete %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *S3Backend) PresignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	u, err := b.client.PresignedGetObject(ctx, b.bucket, key, ttl, nil)
+	if err != nil {
+		return "", fmt.Errorf("storage: presign %s: %w", key, err)
+	}
+	return u.String(), nil
+}
+
+func (b *S3Backend) URI(key string) string {
+	return fmt.Sprintf("s3://%s/%s", b.bucket, strings.TrimPrefix(key, "/"))
+}
+
+func objectInfoFromMinio(key string, info minio.ObjectInfo) ObjectInfo {
+	mode := os.FileMode(0o644)
+	if raw, ok := info.UserMetadata["Mode"]; ok {
+		if parsed, err := parseOctalMode(raw); err == nil {
+			mode = parsed
+		}
+	}
+	return ObjectInfo{
+		Key:          key,
+		Size:         info.Size,
+		Mode:         mode,
+		LastModified: info.LastModified,
+	}
+}
+
+func parseOctalMode(raw string) (os.FileMode, error) {
+	var mode uint32
+	if _, err := fmt.Sscanf(raw, "%o", &mode); err != nil {
+		return 0, err
+	}
+	return os.FileMode(mode), nil
+}
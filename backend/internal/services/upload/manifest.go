@@ -0,0 +1,69 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/Petr1Furious/potato-launcher/backend/internal/services/storage"
+)
+
+// FileEntry records one uploaded file's content address and metadata.
+type FileEntry struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	Mode   uint32 `json:"mode"`
+}
+
+// Manifest maps an upload namespace's files by relative path to the
+// content-addressed object backing them, so a build can reconstruct the
+// original tree and a preflight check can tell a client which files it
+// still needs to send.
+type Manifest struct {
+	Files map[string]FileEntry `json:"files"`
+}
+
+// LoadManifest reads the manifest stored at key, returning an empty
+// Manifest if nothing has been uploaded yet.
+func LoadManifest(ctx context.Context, backend storage.Backend, key string) (*Manifest, error) {
+	rc, _, err := backend.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &Manifest{Files: map[string]FileEntry{}}, nil
+		}
+		return nil, err
+	}
+	defer rc.Close()
+
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	if m.Files == nil {
+		m.Files = map[string]FileEntry{}
+	}
+	return &m, nil
+}
+
+// Save writes m to key as indented JSON.
+func (m *Manifest) Save(ctx context.Context, backend storage.Backend, key string) error {
+	raw, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+	return backend.Put(ctx, key, bytes.NewReader(raw), int64(len(raw)), 0o644)
+}
+
+// Needs reports whether a file with the given path/sha256/size pair still
+// needs to be uploaded, i.e. the manifest doesn't already record an
+// identical entry at that path.
+func (m *Manifest) Needs(relpath, sha256Hex string, size int64) bool {
+	entry, ok := m.Files[relpath]
+	return !ok || entry.SHA256 != sha256Hex || entry.Size != size
+}
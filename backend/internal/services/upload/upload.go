@@ -0,0 +1,52 @@
+// Package upload provides a streaming "materialize to a temp file while
+// hashing" helper and the content-addressed manifest built on top of it, so
+// uploaded files can be checksummed and deduplicated without ever holding an
+// entire upload in memory.
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// Materialized describes a request body that has been written to a local
+// temp file while its SHA-256 checksum was computed in the same pass.
+type Materialized struct {
+	Path   string
+	SHA256 string
+	Size   int64
+}
+
+// Materialize streams r into a temp file under dir in bounded chunks,
+// tee-ing into a SHA-256 hash as it goes, so the upload never has to sit in
+// memory all at once. The caller owns the returned temp file and must
+// remove it once its contents have been persisted elsewhere.
+func Materialize(dir string, r io.Reader) (*Materialized, error) {
+	tmp, err := os.CreateTemp(dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), r)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return &Materialized{
+		Path:   tmp.Name(),
+		SHA256: hex.EncodeToString(hasher.Sum(nil)),
+		Size:   size,
+	}, nil
+}
+
+// ObjectKey returns the content-addressed storage key for a blob with the
+// given SHA-256 checksum, namespaced the same way git's object store is:
+// "objects/<xx>/<sha256>".
+func ObjectKey(sha256Hex string) string {
+	return "objects/" + sha256Hex[:2] + "/" + sha256Hex
+}
@@ -39,6 +39,31 @@ func (s *Store) GetSpec() (*models.BuilderSpec, error) {
 	return readFile(s.path)
 }
 
+// Replace atomically swaps the whole spec for a snapshot restore: it writes
+// to a temp file next to the spec and renames it into place, so a crash
+// mid-write can never leave spec.json truncated or half-written.
+func (s *Store) Replace(spec *models.BuilderSpec) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if spec.Instances == nil {
+		spec.Instances = []models.BuilderInstance{}
+	}
+	raw, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode spec: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return fmt.Errorf("write temp spec: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("rename temp spec: %w", err)
+	}
+	return nil
+}
+
 func (s *Store) Update(mutator func(*models.BuilderSpec) error) (*models.BuilderSpec, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()